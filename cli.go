@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"download-youtube/models"
+)
+
+// options are the flags that only some of the commands use
+type options struct {
+	Config  string
+	Fix     bool
+	Corpus  string
+	Full    bool
+	NoCache bool
+}
+
+type command struct {
+	Usage string
+	// Local commands only read the saved state and don't need the YouTube API
+	Local bool
+	Flags func(fs *flag.FlagSet, opts *options)
+	Run   func(app *App, opts options) error
+}
+
+var commands = map[string]command{
+	"run": {
+		Usage: "Refresh the metadata and download everything not downloaded yet (default)",
+		Flags: syncFlags,
+		Run: func(app *App, opts options) error {
+			opts.apply(app)
+			// Downloading does not use the API quota, so the pending videos are still downloaded
+			syncErr := app.Sync()
+			if syncErr != nil && !errors.Is(syncErr, models.ErrQuotaExceeded) {
+				return syncErr
+			}
+			if syncErr != nil {
+				log.Print("Syncing again next run: ", syncErr)
+			}
+			return errors.Join(syncErr, app.Download.Videos(allVideos))
+		},
+	},
+	"sync": {
+		Usage: "Refresh the metadata from YouTube only",
+		Flags: syncFlags,
+		Run: func(app *App, opts options) error {
+			opts.apply(app)
+			return app.Sync()
+		},
+	},
+	"download": {
+		Usage: "Download the pending videos and thumbnails only",
+		Run: func(app *App, opts options) error {
+			return app.Download.Videos(pendingVideos)
+		},
+	},
+	"retry": {
+		Usage: "Download the videos that failed before again",
+		Run: func(app *App, opts options) error {
+			return app.Download.Videos(erroredVideos)
+		},
+	},
+	"status": {
+		Usage: "Print how many videos are downloaded, pending and errored",
+		Local: true,
+		Run: func(app *App, opts options) error {
+			return app.Download.Status()
+		},
+	},
+	"nfo": {
+		Usage: "Re-write the episode and season NFOs that have changed, keeping what the user has edited",
+		Local: true,
+		Run: func(app *App, opts options) error {
+			return app.Download.RefreshNfos()
+		},
+	},
+	"renumber": {
+		Usage: "Number the episodes of every season again by publish date and rename their files",
+		Local: true,
+		Run: func(app *App, opts options) error {
+			return app.Renumber()
+		},
+	},
+	"migrate": {
+		Usage: "Copy the videos from the JSON file into a SQLite database",
+		Local: true,
+		Run: func(app *App, opts options) error {
+			return app.Migrate()
+		},
+	},
+	"titles": {
+		Usage: "Check the title rules against a list of titles and what should be read from them",
+		Local: true,
+		Flags: func(fs *flag.FlagSet, opts *options) {
+			fs.StringVar(&opts.Corpus, "corpus", "TestData/title-corpus.json", "JSON file with the titles to check")
+		},
+		Run: func(app *App, opts options) error {
+			return app.YT.CheckTitles(opts.Corpus)
+		},
+	},
+	"verify": {
+		Usage: "Check the files on disk against the saved state",
+		Local: true,
+		Flags: func(fs *flag.FlagSet, opts *options) {
+			fs.BoolVar(&opts.Fix, "fix", false, "mark missing files to be downloaded again")
+		},
+		Run: func(app *App, opts options) error {
+			return app.Download.Verify(opts.Fix)
+		},
+	},
+}
+
+// syncFlags are the flags of the commands that sync with YouTube
+func syncFlags(fs *flag.FlagSet, opts *options) {
+	fs.BoolVar(&opts.Full, "full", false, "walk every page instead of stopping at the first page of saved videos")
+	fs.BoolVar(&opts.NoCache, "no-cache", false, "fetch every API response from YouTube instead of the cache")
+}
+
+// apply sets the sync flags on the app
+func (opts options) apply(app *App) {
+	app.YT.Full = opts.Full
+	if opts.NoCache {
+		app.YT.Cache = nil
+	}
+}
+
+// envVarFlags lets every command override the values from the .env file
+func envVarFlags(fs *flag.FlagSet, envVar *models.EnvVar) {
+	fs.StringVar(&envVar.ApiKey, "api-key", envVar.ApiKey, "YouTube API key (YT_API_KEY)")
+	fs.StringVar(&envVar.ChannelID, "channel-id", envVar.ChannelID, "YouTube channel ID (YT_CHANNEL_ID)")
+	fs.StringVar(&envVar.PlaylistID, "playlist-id", envVar.PlaylistID, "YouTube playlist ID (YT_PLAYLIST_ID)")
+	fs.StringVar(&envVar.ChannelName, "channel-name", envVar.ChannelName, "name of the show (YT_CHANNEL_NAME)")
+	fs.StringVar(&envVar.SeasonStartYear, "season-start-year", envVar.SeasonStartYear, "year of season 1 (SEASON_START_YEAR)")
+	fs.StringVar(&envVar.SaveLoc, "save-location", envVar.SaveLoc, "where the show is saved (SAVE_LOCATION)")
+	fs.StringVar(&envVar.Workers, "workers", envVar.Workers, "videos downloaded at the same time (DOWNLOAD_WORKERS)")
+	fs.StringVar(&envVar.RateLimit, "rate-limit", envVar.RateLimit, "minimum time between requests (DOWNLOAD_RATE_LIMIT)")
+	fs.StringVar(&envVar.MaxResolution, "max-resolution", envVar.MaxResolution, "best resolution to download (MAX_RESOLUTION)")
+	fs.StringVar(&envVar.VideoCodecs, "video-codecs", envVar.VideoCodecs, "video codecs in order of preference (VIDEO_CODECS)")
+	fs.StringVar(&envVar.Containers, "containers", envVar.Containers, "containers in order of preference (CONTAINERS)")
+	fs.StringVar(&envVar.AudioBitrate, "audio-bitrate", envVar.AudioBitrate, "preferred audio bitrate in kbps (AUDIO_BITRATE)")
+	fs.StringVar(&envVar.AllowMuxed, "allow-muxed", envVar.AllowMuxed, "accept formats with both audio and video (ALLOW_MUXED)")
+	fs.StringVar(&envVar.Numbering, "numbering", envVar.Numbering, "auto or date, how seasons and episodes are numbered (NUMBERING_MODE)")
+	fs.StringVar(&envVar.TemplateDir, "template-dir", envVar.TemplateDir, "folder with NFO templates replacing the built-in ones (TEMPLATE_DIR)")
+	fs.StringVar(&envVar.FetchMode, "fetch-mode", envVar.FetchMode, "uploads or search, how the videos of a channel are listed (FETCH_MODE)")
+	fs.StringVar(&envVar.SeasonStrategy, "season-strategy", envVar.SeasonStrategy, "year, fixed, playlist, dates or regex, how the season of a video is decided (SEASON_STRATEGY)")
+	fs.StringVar(&envVar.SeasonDates, "season-dates", envVar.SeasonDates, "start dates of the seasons for the dates strategy, e.g. 2019-01-01,2021-06-01 (SEASON_DATES)")
+	fs.StringVar(&envVar.TitlePattern, "title-pattern", envVar.TitlePattern, "regex with season, episode and title groups for the regex strategy (TITLE_PATTERN)")
+	fs.StringVar(&envVar.TitleRules, "title-rules", envVar.TitleRules, "JSON file with the rules reading the season and episode from titles (TITLE_RULES)")
+	fs.StringVar(&envVar.StateStore, "state-store", envVar.StateStore, "json or sqlite, where what is known about the videos is saved (STATE_STORE)")
+	fs.StringVar(&envVar.QuotaBudget, "quota-budget", envVar.QuotaBudget, "most YouTube API quota units used in a day, 0 is no limit (API_QUOTA_BUDGET)")
+	fs.StringVar(&envVar.QuotaFile, "quota-file", envVar.QuotaFile, "where the YouTube API quota used today is saved (API_QUOTA_FILE)")
+	fs.StringVar(&envVar.CacheTTL, "cache-ttl", envVar.CacheTTL, "how long an API response is used without asking YouTube again (API_CACHE_TTL)")
+	fs.StringVar(&envVar.FilenameProfile, "filename-profile", envVar.FilenameProfile, "posix, windows or smb, the file system the files are saved on (FILENAME_PROFILE)")
+}
+
+// parseCommand picks the command from the first argument and parses its flags on top of the env values
+func parseCommand(args []string, envVar *models.EnvVar) (command, options, error) {
+	var opts options
+
+	name := "run"
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		return cmd, opts, fmt.Errorf("unknown command: %s", name)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	envVarFlags(fs, envVar)
+	fs.StringVar(&opts.Config, "config", os.Getenv("CONFIG_FILE"), "JSON file with every source to process (CONFIG_FILE)")
+	if cmd.Flags != nil {
+		cmd.Flags(fs, &opts)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cmd, opts, err
+	}
+
+	return cmd, opts, nil
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].Usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h to see the flags\n", os.Args[0])
+}
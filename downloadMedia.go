@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,88 +11,251 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"download-youtube/models"
+	"download-youtube/stateStore"
 
 	"github.com/kkdai/youtube/v2"
 )
 
 type Download struct {
-	JsonFilePath string
-	SaveLoc      string
-	ShowName     string
+	Store     stateStore.Store
+	SaveLoc   string
+	ShowName  string
+	Workers   int
+	RateLimit time.Duration
+	Policy    models.FormatPolicy
+	// ChannelPath is where the channel info from the last sync is saved
+	ChannelPath string
+	Templates   nfoTemplates
 }
 
-func (d Download) Videos() {
-	jsonFile, err := os.Open(d.JsonFilePath)
+// selection picks the videos to download from the store
+type selection func(store stateStore.Store) ([]models.Video, error)
+
+// Videos downloads the thumbnail and video for every selected episode using a pool of workers.
+// Every episode is saved to the store as soon as it is done.
+// Returns the errors of the failed episodes, stops handing out episodes when YouTube rate limits us
+func (d Download) Videos(selected selection) error {
+	videos, err := selected(d.Store)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer jsonFile.Close()
 
-	var videos []models.Video
+	show := d.show()
 
-	jsonByte, _ := io.ReadAll(jsonFile)
-	json.Unmarshal(jsonByte, &videos)
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-	client := youtube.Client{
-		HTTPClient: &http.Client{
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				if len(via) >= 10 {
-					return fmt.Errorf("stopped after 10 redirects")
-				}
-				if strings.Contains(req.URL.String(), "google.com/sorry") {
-					return fmt.Errorf("hit Google Sorry page, possible rate limit or CAPTCHA")
-				}
-				return nil
-			},
-			Timeout: 10 * time.Second,
+	transport := newRateLimitedTransport(d.RateLimit, http.DefaultTransport)
+	defer transport.Stop()
+
+	httpClient := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			if strings.Contains(req.URL.String(), "google.com/sorry") {
+				return fmt.Errorf("hit Google Sorry page, possible CAPTCHA: %w", models.ErrRateLimited)
+			}
+			return nil
 		},
+		Timeout: 10 * time.Second,
 	}
 
-	for i, video := range videos {
-		printVideoTitle(video.Title)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		d.checkSeasonFolderExist(video.Season)
-		generateEpisodeNfo(video)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var episodeErrs []error
+	jobs := make(chan int)
 
-		if !video.ImageSaved {
-			err = d.image(video)
-			if err != nil {
-				log.Print(err)
-			} else {
-				log.Printf("Successfully Downloaded image to: %s", video.Filepath)
-				videos[i].ImageSaved = true
+	log.Printf("Downloading %d videos using %d workers", len(videos), workers)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each worker has its own client as it keeps state between requests,
+			// the HTTP client and with it the rate limit is shared
+			client := youtube.Client{HTTPClient: httpClient}
+
+			for i := range jobs {
+				mu.Lock()
+				video := videos[i]
+				mu.Unlock()
+
+				video, err := d.episode(video, show, client)
+
+				mu.Lock()
+				if err != nil {
+					episodeErrs = append(episodeErrs, fmt.Errorf("%s: %w", video.Title, err))
+				}
+				if errors.Is(err, models.ErrRateLimited) {
+					log.Print("Rate limited by YouTube, not starting any more downloads")
+					cancel()
+				}
+				videos[i] = video
+				mu.Unlock()
+
+				switch {
+				case video.Downloaded:
+					err = d.Store.MarkDownloaded(video)
+				case video.Error != "":
+					err = d.Store.RecordError(video)
+				default:
+					err = d.Store.Upsert(video)
+				}
+				if err != nil {
+					log.Print("Problem with saving the video: ", err)
+					continue
+				}
+
+				log.Print("Successfully Downloaded, merged the video and saved the state")
 			}
+		}()
+	}
+
+dispatch:
+	for i := range videos {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// The season NFOs list every episode of the season, not only the selected ones
+	all, err := d.Store.Load()
+	if err != nil {
+		episodeErrs = append(episodeErrs, err)
+	} else {
+		d.Seasons(all)
+	}
+
+	return errors.Join(episodeErrs...)
+}
+
+// allVideos selects every video, the ones already downloaded are skipped when processing them
+func allVideos(store stateStore.Store) ([]models.Video, error) {
+	return store.Load()
+}
+
+// pendingVideos selects the videos missing media that have not failed before
+func pendingVideos(store stateStore.Store) ([]models.Video, error) {
+	return store.Pending()
+}
+
+// erroredVideos selects the videos that failed before
+func erroredVideos(store stateStore.Store) ([]models.Video, error) {
+	videos, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var errored []models.Video
+	for _, video := range videos {
+		if video.Error != "" {
+			errored = append(errored, video)
+		}
+	}
+	return errored, nil
+}
+
+// episode downloads the thumbnail and the video and returns the video with the updated state
+func (d Download) episode(video models.Video, show models.Show, client youtube.Client) (models.Video, error) {
+	var videoErr error
+
+	printVideoTitle(video.Title)
+
+	d.checkSeasonFolderExist(video.Season)
+
+	if !video.ImageSaved {
+		err := d.image(video)
+		if err != nil {
+			log.Print(err)
 		} else {
-			log.Print("Thumbnail already downloaded")
+			log.Printf("Successfully Downloaded image to: %s", video.Filepath)
+			video.ImageSaved = true
 		}
+	} else {
+		log.Print("Thumbnail already downloaded")
+	}
 
-		if !video.Downloaded {
-			err = d.video(video, client)
+	if !video.Downloaded {
+		err := rateLimitError(d.video(video, client))
+		if err != nil {
+			videoErr = err
+			log.Print(err)
+			video.Error = err.Error()
+			removeMediaFiles(video)
+			log.Print("Waiting 10s")
+			time.Sleep(10 * time.Second)
+		} else {
+			video.Downloaded = true
+			video.Error = ""
+
+			media, err := probeMedia(video.Filepath + ".mp4")
 			if err != nil {
 				log.Print(err)
-				videos[i].Error = err.Error()
-				removeMediaFiles(video)
-				log.Print("Waiting 10s")
-				time.Sleep(10 * time.Second)
 			} else {
-				videos[i].Downloaded = true
-				videos[i].Error = ""
+				video.Media = media
 			}
-		} else {
-			log.Print("Video already downloaded")
 		}
+	} else {
+		log.Print("Video already downloaded")
+	}
 
-		videosJSON, _ := json.Marshal(videos)
-		err = os.WriteFile(d.JsonFilePath, videosJSON, 0644)
-		if err != nil {
-			log.Print("Problem with writting JSON", err)
+	// Only once the video exists, so Kodi does not list episodes that are not there
+	if video.Downloaded {
+		if _, err := generateEpisodeNfo(video, show, d.Templates.Episode); err != nil {
+			log.Print(err)
 		}
+	}
+
+	return video, videoErr
+}
+
+// rateLimitError marks a 429 from YouTube as rate limited, so the run stops instead of trying the next episode
+func rateLimitError(err error) error {
+	var statusErr youtube.ErrUnexpectedStatusCode
+	if errors.As(err, &statusErr) && int(statusErr) == http.StatusTooManyRequests {
+		return fmt.Errorf("%w: %w", models.ErrRateLimited, err)
+	}
+	return err
+}
+
+// show returns the show with the channel info saved by the last sync, only the name if there is none
+func (d Download) show() models.Show {
+	show := models.Show{Name: d.ShowName}
+
+	channelByte, err := os.ReadFile(d.ChannelPath)
+	if err != nil {
+		return show
+	}
+	if err := json.Unmarshal(channelByte, &show.Channel); err != nil {
+		log.Print("Problem reading channel info: ", err)
+	}
 
-		log.Print("Successfully Downloaded, merged the video and updated the JSON file")
+	return show
+}
+
+// writeChannel saves the channel info for the NFO templates
+func (d Download) writeChannel(channel models.Channel) error {
+	channelJSON, err := json.Marshal(channel)
+	if err != nil {
+		return err
 	}
+	return models.WriteState(d.ChannelPath, channelJSON, 0)
 }
 
 // checkSeasonFolderExist creates the season folder if it's missing
@@ -115,39 +280,36 @@ func (d Download) checkSeasonFolderExist(season string) error {
 	return nil
 }
 
-// DownloadVideo gets the YouTube video, looking for 720p format.
-// Have to get both audio and video stream to then merge them into one file
+// DownloadVideo gets the YouTube video, using the format policy to pick the formats.
+// Usually have to get both audio and video stream to then merge them into one file
 func (d Download) video(v models.Video, client youtube.Client) error {
 	log.Print("Downloading video from: ", v.URL)
 	video, err := client.GetVideo(v.URL)
 	if err != nil {
-		return fmt.Errorf("error fetching video info: %v", err)
+		return fmt.Errorf("error fetching video info: %w", err)
 	}
 
-	var videoFormat, audioFormat *youtube.Format
-	for _, format := range video.Formats {
-		if format.QualityLabel == "720p" && format.AudioChannels == 0 {
-			videoFormat = &format
-		}
-		if format.AudioChannels > 0 && format.AudioQuality == "AUDIO_QUALITY_MEDIUM" {
-			audioFormat = &format
-		}
-		if videoFormat != nil && audioFormat != nil {
-			break
-		}
+	formats, err := selectFormats(video.Formats, d.Policy)
+	if err != nil {
+		return err
 	}
 
-	if videoFormat == nil || audioFormat == nil {
-		log.Fatal("Suitable video or audio format not found")
+	if formats.Muxed != nil {
+		log.Printf("Downloading muxed format %s", formats.Muxed.QualityLabel)
+		if err := d.stream(context.Background(), client, video, formats.Muxed, v.Filepath+".mp4"); err != nil {
+			return err
+		}
+		_ = os.Remove(sidecarPath(v.Filepath + ".mp4"))
+		return nil
 	}
 
+	log.Printf("Downloading video format %s (%s) and audio format %dkbps",
+		formats.Video.QualityLabel, formats.Video.MimeType, formats.Audio.Bitrate/1000)
+	videoFormat, audioFormat := formats.Video, formats.Audio
+
 	videoFileName := v.Filepath + "_video.mp4"
-	err = d.stream(client, video, videoFormat, videoFileName)
-	if err != nil {
-		return err
-	}
 	audioFileName := v.Filepath + "_audio.mp4"
-	err = d.stream(client, video, audioFormat, audioFileName)
+	err = d.streams(client, video, videoFormat, videoFileName, audioFormat, audioFileName)
 	if err != nil {
 		return err
 	}
@@ -157,22 +319,35 @@ func (d Download) video(v models.Video, client youtube.Client) error {
 		return err
 	}
 
-	_ = os.Remove(videoFileName)
-	_ = os.Remove(audioFileName)
+	// The streams are only removed once merged, a failed merge is tried again without downloading them
+	for _, filename := range []string{videoFileName, audioFileName} {
+		_ = os.Remove(filename)
+		_ = os.Remove(sidecarPath(filename))
+	}
 
 	return nil
 }
 
+// removeMediaFiles cleans up after a failed download.
+// Partial streams with a sidecar are kept so the next run can resume them
 func removeMediaFiles(v models.Video) {
 	audioFileName := v.Filepath + "_audio.mp4"
 	videoFileName := v.Filepath + "_video.mp4"
 
-	_ = os.Remove(videoFileName)
-	_ = os.Remove(audioFileName)
-	_ = os.Remove(v.Filepath + ".mp4")
+	removeUnlessResumable(videoFileName)
+	removeUnlessResumable(audioFileName)
+	removeUnlessResumable(v.Filepath + ".mp4")
 
 }
 
+func removeUnlessResumable(filename string) {
+	if _, err := os.Stat(sidecarPath(filename)); err == nil {
+		log.Print("Keeping download for next run: ", filename)
+		return
+	}
+	_ = os.Remove(filename)
+}
+
 // mergeAudioVideo takes the audio and video file and merges it into one file
 func mergeAudioVideo(filePath, videoFileName, audioFileName string) error {
 	mergedFileName := filePath + ".mp4"
@@ -183,7 +358,7 @@ func mergeAudioVideo(filePath, videoFileName, audioFileName string) error {
 	ffmpegCmd.Stderr = os.Stderr
 
 	if err := ffmpegCmd.Run(); err != nil {
-		return fmt.Errorf("error merging audio and video: %s", err)
+		return fmt.Errorf("%w: error merging audio and video: %s", models.ErrFFmpegFailed, err)
 	}
 
 	log.Print("Merging completed: ", mergedFileName)
@@ -191,11 +366,57 @@ func mergeAudioVideo(filePath, videoFileName, audioFileName string) error {
 	return nil
 }
 
+// streams downloads the video and audio stream at the same time.
+// If one of them fails the other one is cancelled, both have stopped writing when it returns
+func (d Download) streams(client youtube.Client, video *youtube.Video, videoFormat *youtube.Format, videoFileName string, audioFormat *youtube.Format, audioFileName string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var once sync.Once
+	var streamErr error
+	fail := func(err error) {
+		// Only keep the first error, the sibling will fail with a cancelled context
+		once.Do(func() {
+			streamErr = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := d.stream(ctx, client, video, videoFormat, videoFileName); err != nil {
+			fail(err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := d.stream(ctx, client, video, audioFormat, audioFileName); err != nil {
+			fail(err)
+		}
+	}()
+	wg.Wait()
+
+	return streamErr
+}
+
 // DownloadStream gets the YouTube audio or video stream and Downloads it
-func (d Download) stream(client youtube.Client, video *youtube.Video, format *youtube.Format, filename string) error {
-	stream, _, err := client.GetStream(video, format)
+// Streams with a known content length are resumed from what is already on disk
+func (d Download) stream(ctx context.Context, client youtube.Client, video *youtube.Video, format *youtube.Format, filename string) error {
+	if format.ContentLength > 0 {
+		err := d.resumableStream(ctx, client, video, format, filename)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Stream Downloaded successfully: %s\n", filename)
+		return nil
+	}
+
+	stream, _, err := client.GetStreamContext(ctx, video, format)
 	if err != nil {
-		return fmt.Errorf("get the video stream - %s", err)
+		return fmt.Errorf("get the video stream - %w", err)
 	}
 	defer stream.Close()
 
@@ -207,7 +428,7 @@ func (d Download) stream(client youtube.Client, video *youtube.Video, format *yo
 
 	_, err = io.Copy(file, stream)
 	if err != nil {
-		return fmt.Errorf("copy - Problem streaming the video - %s", err)
+		return fmt.Errorf("copy - Problem streaming the video - %w", err)
 	}
 
 	fmt.Printf("Stream Downloaded successfully: %s\n", filename)
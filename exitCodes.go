@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"download-youtube/models"
+)
+
+// Exit codes so wrapper scripts can tell why a run stopped
+const (
+	exitOK                = 0
+	exitError             = 1
+	exitConfig            = 2
+	exitQuotaExceeded     = 3
+	exitRateLimited       = 4
+	exitFormatUnavailable = 5
+	exitFFmpegFailed      = 6
+	exitStateCorrupt      = 7
+)
+
+// exitCode maps the error to an exit code, the ones that need attention before the next run are checked first
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, models.ErrStateCorrupt):
+		return exitStateCorrupt
+	case errors.Is(err, models.ErrInvalidConfig):
+		return exitConfig
+	case errors.Is(err, models.ErrQuotaExceeded):
+		return exitQuotaExceeded
+	case errors.Is(err, models.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, models.ErrFFmpegFailed):
+		return exitFFmpegFailed
+	case errors.Is(err, models.ErrFormatUnavailable):
+		return exitFormatUnavailable
+	default:
+		return exitError
+	}
+}
+
+// exit logs the error and exits with the matching exit code
+func exit(err error) {
+	if err != nil {
+		log.Print(err)
+	}
+	os.Exit(exitCode(err))
+}
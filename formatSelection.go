@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"download-youtube/models"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// selectedFormats holds either a separate video and audio format, or a single muxed format
+type selectedFormats struct {
+	Video *youtube.Format
+	Audio *youtube.Format
+	Muxed *youtube.Format
+}
+
+// selectFormats goes through the resolutions of the policy from best to worst and picks the first one available.
+// A muxed format is only used when there is no separate video and audio pair at that resolution
+func selectFormats(formats youtube.FormatList, policy models.FormatPolicy) (selectedFormats, error) {
+	var selected selectedFormats
+
+	selected.Audio = selectAudioFormat(formats, policy)
+
+	for _, resolution := range policy.Resolutions {
+		if selected.Audio != nil {
+			selected.Video = selectVideoFormat(formats, policy, resolution, false)
+			if selected.Video != nil {
+				return selected, nil
+			}
+		}
+
+		if policy.AllowMuxed {
+			selected.Muxed = selectVideoFormat(formats, policy, resolution, true)
+			if selected.Muxed != nil {
+				selected.Audio = nil
+				return selected, nil
+			}
+		}
+	}
+
+	return selected, fmt.Errorf("%w: no video or audio format for max %dp, codecs %s, containers %s",
+		models.ErrFormatUnavailable, policy.Resolutions[0], strings.Join(policy.VideoCodecs, ","), strings.Join(policy.Containers, ","))
+}
+
+// selectVideoFormat picks the best format at the resolution, ranked by codec, container and bitrate
+func selectVideoFormat(formats youtube.FormatList, policy models.FormatPolicy, resolution int, muxed bool) *youtube.Format {
+	var best *youtube.Format
+	for i := range formats {
+		format := &formats[i]
+		if !strings.HasPrefix(format.MimeType, "video/") || (format.AudioChannels > 0) != muxed {
+			continue
+		}
+		if formatResolution(format) != resolution {
+			continue
+		}
+		if rank(policy.VideoCodecs, formatCodec(format)) < 0 || rank(policy.Containers, formatContainer(format)) < 0 {
+			continue
+		}
+
+		if best == nil || betterVideoFormat(format, best, policy) {
+			best = format
+		}
+	}
+	return best
+}
+
+func betterVideoFormat(a, b *youtube.Format, policy models.FormatPolicy) bool {
+	if codecA, codecB := rank(policy.VideoCodecs, formatCodec(a)), rank(policy.VideoCodecs, formatCodec(b)); codecA != codecB {
+		return codecA < codecB
+	}
+	if containerA, containerB := rank(policy.Containers, formatContainer(a)), rank(policy.Containers, formatContainer(b)); containerA != containerB {
+		return containerA < containerB
+	}
+	return a.Bitrate > b.Bitrate
+}
+
+// audioBitrateTolerance is how far above the preferred bitrate a format may average and still count as at it. AAC
+// at 128k averages a little over 128000 bps, e.g. itag 140 at about 129500
+const audioBitrateTolerance = 0.1
+
+// selectAudioFormat picks the highest average bitrate at or below the preferred bitrate, falling back to the lowest
+// one above it
+func selectAudioFormat(formats youtube.FormatList, policy models.FormatPolicy) *youtube.Format {
+	preferred := int(float64(policy.AudioBitrate*1000) * (1 + audioBitrateTolerance))
+
+	var best *youtube.Format
+	for i := range formats {
+		format := &formats[i]
+		if !strings.HasPrefix(format.MimeType, "audio/") || format.AudioChannels == 0 {
+			continue
+		}
+		if rank(policy.Containers, formatContainer(format)) < 0 {
+			continue
+		}
+
+		if best == nil || betterAudioFormat(format, best, preferred, policy) {
+			best = format
+		}
+	}
+	return best
+}
+
+func betterAudioFormat(a, b *youtube.Format, preferred int, policy models.FormatPolicy) bool {
+	bitrateA, bitrateB := audioBitrate(a), audioBitrate(b)
+	underA, underB := bitrateA <= preferred, bitrateB <= preferred
+	if underA != underB {
+		return underA
+	}
+	if bitrateA != bitrateB {
+		if underA {
+			return bitrateA > bitrateB
+		}
+		return bitrateA < bitrateB
+	}
+	return rank(policy.Containers, formatContainer(a)) < rank(policy.Containers, formatContainer(b))
+}
+
+// audioBitrate is the average bitrate of the format, Bitrate is the peak and is used when there is no average
+func audioBitrate(format *youtube.Format) int {
+	if format.AverageBitrate > 0 {
+		return format.AverageBitrate
+	}
+	return format.Bitrate
+}
+
+// formatResolution reads the height from the quality label, e.g. 1080 from "1080p60"
+func formatResolution(format *youtube.Format) int {
+	label := format.QualityLabel
+	end := strings.IndexFunc(label, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		label = label[:end]
+	}
+	resolution, _ := strconv.Atoi(label)
+	return resolution
+}
+
+// formatContainer reads the container from the mime type, e.g. mp4 from `video/mp4; codecs="avc1.64001F"`
+func formatContainer(format *youtube.Format) string {
+	mimeType, _, _ := strings.Cut(format.MimeType, ";")
+	_, container, _ := strings.Cut(mimeType, "/")
+	return strings.ToLower(strings.TrimSpace(container))
+}
+
+// formatCodec reads the first codec from the mime type, e.g. avc1 from `video/mp4; codecs="avc1.64001F"`
+func formatCodec(format *youtube.Format) string {
+	_, codecs, found := strings.Cut(format.MimeType, `codecs="`)
+	if !found {
+		return ""
+	}
+	codec := strings.ToLower(strings.TrimSpace(strings.Split(strings.Trim(codecs, `"`), ",")[0]))
+	codec, _, _ = strings.Cut(codec, ".")
+	if codec == "vp09" {
+		codec = "vp9"
+	}
+	return codec
+}
+
+// rank returns the position in the preference list, -1 if it's not wanted at all
+func rank(preferences []string, value string) int {
+	return slices.Index(preferences, value)
+}
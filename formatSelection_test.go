@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"download-youtube/models"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// audioFormats are the audio formats YouTube offers for a regular upload, with the values of a real response
+var audioFormats = youtube.FormatList{
+	{ItagNo: 139, MimeType: `audio/mp4; codecs="mp4a.40.5"`, Bitrate: 50290, AverageBitrate: 48784, AudioChannels: 2, AudioQuality: "AUDIO_QUALITY_LOW"},
+	{ItagNo: 140, MimeType: `audio/mp4; codecs="mp4a.40.2"`, Bitrate: 130622, AverageBitrate: 129478, AudioChannels: 2, AudioQuality: "AUDIO_QUALITY_MEDIUM"},
+	{ItagNo: 249, MimeType: `audio/webm; codecs="opus"`, Bitrate: 53542, AverageBitrate: 49424, AudioChannels: 2, AudioQuality: "AUDIO_QUALITY_LOW"},
+	{ItagNo: 250, MimeType: `audio/webm; codecs="opus"`, Bitrate: 70117, AverageBitrate: 64906, AudioChannels: 2, AudioQuality: "AUDIO_QUALITY_LOW"},
+	{ItagNo: 251, MimeType: `audio/webm; codecs="opus"`, Bitrate: 138898, AverageBitrate: 126853, AudioChannels: 2, AudioQuality: "AUDIO_QUALITY_MEDIUM"},
+}
+
+func TestSelectAudioFormat(t *testing.T) {
+	withoutAverage := make(youtube.FormatList, len(audioFormats))
+	for i, format := range audioFormats {
+		format.AverageBitrate = 0
+		withoutAverage[i] = format
+	}
+
+	tests := []struct {
+		name       string
+		formats    youtube.FormatList
+		bitrate    int
+		containers []string
+		want       int
+	}{
+		{"default picks AAC 128k", audioFormats, 128, []string{"mp4", "webm"}, 140},
+		{"webm only", audioFormats, 128, []string{"webm"}, 251},
+		{"mp4 only", audioFormats, 128, []string{"mp4"}, 140},
+		{"64k picks opus 64k", audioFormats, 64, []string{"mp4", "webm"}, 250},
+		{"48k picks opus 50k", audioFormats, 48, []string{"mp4", "webm"}, 249},
+		{"below everything picks the lowest", audioFormats, 32, []string{"mp4", "webm"}, 139},
+		{"above everything picks the highest", audioFormats, 320, []string{"mp4", "webm"}, 140},
+		{"peak bitrate without an average", withoutAverage, 128, []string{"mp4"}, 140},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := models.FormatPolicy{AudioBitrate: test.bitrate, Containers: test.containers}
+
+			got := selectAudioFormat(test.formats, policy)
+			if got == nil {
+				t.Fatalf("no format, want itag %d", test.want)
+			}
+			if got.ItagNo != test.want {
+				t.Errorf("itag %d, want %d", got.ItagNo, test.want)
+			}
+		})
+	}
+}
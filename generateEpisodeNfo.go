@@ -1,72 +1,70 @@
 package main
 
 import (
+	"bytes"
+	"encoding/xml"
 	"fmt"
+	"html"
 	"log"
 	"os"
+	"regexp"
+	"strconv"
 	"text/template"
 
 	"download-youtube/models"
 )
 
-// generateEpisodeNfo created an .nfo file with all data based on the extracted data from youtube
-func generateEpisodeNfo(video models.Video) {
-	// Template string
-	xmlTemplate := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
-<!--created on {{.CreationDate}} - tinyMediaManager {{.Version}}-->
-<episodedetails>
-  <title>{{.Title}}</title>
-  <originaltitle>{{.OriginalTitle}}</originaltitle>
-  <showtitle>{{.ShowTitle}}</showtitle>
-  <season>{{.Season}}</season>
-  <episode>{{.Episode}}</episode>
-  <displayseason>{{.DisplaySeason}}</displayseason>
-  <displayepisode>{{.DisplayEpisode}}</displayepisode>
-  <id>{{.ID}}</id>
-  <ratings>{{.Ratings}}</ratings>
-  <userrating>{{.UserRating}}</userrating>
-  <plot>{{.Plot}}</plot>
-  <runtime>{{.Runtime}}</runtime>
-  <mpaa>{{.MPAA}}</mpaa>
-  <premiered>{{.Premiered}}</premiered>
-  <aired>{{.Aired}}</aired>
-  <watched>{{.Watched}}</watched>
-  <playcount>{{.PlayCount}}</playcount>
-  <trailer>{{.Trailer}}</trailer>
-  <dateadded>{{.DateAdded}}</dateadded>
-  <epbookmark>{{.EpBookmark}}</epbookmark>
-  <code>{{.Code}}</code>
-  <fileinfo>
-    <streamdetails>
-      <video>
-        <codec>{{.VideoCodec}}</codec>
-        <aspect>{{.VideoAspect}}</aspect>
-        <width>{{.VideoWidth}}</width>
-        <height>{{.VideoHeight}}</height>
-        <durationinseconds>{{.VideoDuration}}</durationinseconds>
-        <stereomode>{{.StereoMode}}</stereomode>
-      </video>
-    </streamdetails>
-  </fileinfo>
-  <!--tinyMediaManager meta data-->
-  <source>{{.Source}}</source>
-  <original_filename>{{.OriginalFilename}}</original_filename>
-  <user_note>{{.UserNote}}</user_note>
-  <episode_groups>
-    <group episode="{{.GroupEpisode}}" id="{{.GroupID}}" name="{{.GroupName}}" season="{{.GroupSeason}}"/>
-  </episode_groups>
-</episodedetails>`
+// RefreshNfos re-writes the episode and season NFOs from the saved data, only the ones that have changed
+func (d Download) RefreshNfos() error {
+	videos, err := d.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	show := d.show()
+
+	written := 0
+	downloaded := 0
+	for _, video := range videos {
+		if !video.Downloaded {
+			continue
+		}
+		downloaded++
+
+		changed, err := generateEpisodeNfo(video, show, d.Templates.Episode)
+		if err != nil {
+			log.Printf("%s: %v", video.Title, err)
+			continue
+		}
+		if changed {
+			written++
+		}
+	}
+
+	d.Seasons(videos)
+
+	log.Printf("Updated %d of %d episode NFO files", written, downloaded)
 
+	return nil
+}
+
+// generateEpisodeNfo created an .nfo file with all data based on the extracted data from youtube.
+// An existing file is only re-written when the content has changed, keeping the fields the user has edited.
+// Returns if the file was written
+func generateEpisodeNfo(video models.Video, show models.Show, tmpl *template.Template) (bool, error) {
 	// Define the episode details
 	episode := models.NFOEpisodeDetails{
 		CreationDate:     "2024-07-25 15:06:07",
 		Title:            video.Title,
 		OriginalTitle:    video.Title,
-		ShowTitle:        video.Title,
+		ShowTitle:        show.Name,
 		Season:           video.Season,
 		Episode:          video.Episode,
 		Plot:             video.Description,
-		Runtime:          "0",
+		Runtime:          strconv.Itoa(video.Duration / 60),
+		RatingValue:      likeRating(video),
+		RatingVotes:      strconv.FormatInt(video.LikeCount, 10),
+		Tags:             video.Tags,
 		MPAA:             "",
 		Premiered:        video.PublishedAt,
 		Aired:            video.PublishedAt,
@@ -79,40 +77,107 @@ func generateEpisodeNfo(video models.Video) {
 		VideoAspect:      "0.0",
 		VideoWidth:       "1280",
 		VideoHeight:      "720",
-		VideoDuration:    "0",
+		VideoDuration:    strconv.Itoa(video.Duration),
 		StereoMode:       "",
 		Source:           video.URL,
 		OriginalFilename: video.Filename,
 		UserNote:         "",
 	}
 
+	if video.Category != "" {
+		episode.Genres = []string{video.Category}
+	}
+
+	// The real stream details once the file has been downloaded and probed
+	if video.Media != nil {
+		episode.VideoCodec = video.Media.VideoCodec
+		episode.VideoAspect = strconv.FormatFloat(video.Media.Aspect, 'f', 2, 64)
+		episode.VideoWidth = strconv.Itoa(video.Media.Width)
+		episode.VideoHeight = strconv.Itoa(video.Media.Height)
+		episode.VideoDuration = strconv.Itoa(int(video.Media.Duration))
+		episode.Runtime = strconv.Itoa(int(video.Media.Duration) / 60)
+		episode.AudioCodec = video.Media.AudioCodec
+		episode.AudioChannels = strconv.Itoa(video.Media.AudioChannels)
+		episode.AudioLanguage = video.Media.AudioLanguage
+		if episode.AudioLanguage == "" {
+			episode.AudioLanguage = video.AudioLanguage
+		}
+	}
+
 	filename := fmt.Sprintf("%s.nfo", video.Filepath)
 
-	if _, err := os.Stat(filename); err == nil {
-		log.Print("NFO file already exist, skipping")
-		return
+	existing, err := os.ReadFile(filename)
+	if err == nil {
+		keepUserFields(existing, &episode)
 	}
 
-	// Parse the template
-	tmpl, err := template.New("episodedetails").Parse(xmlTemplate)
+	written, err := writeIfChanged(tmpl, episodeTemplateData{NFOEpisodeDetails: episode, Video: video, Show: show}, filename)
 	if err != nil {
-		log.Print("Error parsing template:", err)
-		return
+		return false, err
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Print("Error creating file:", err)
-		return
+	if written {
+		log.Printf(".nfo file written successfully: %s", filename)
 	}
-	defer file.Close()
 
-	// Execute the template and write to the file
-	err = tmpl.Execute(file, episode)
-	if err != nil {
-		log.Print("Error executing template:", err)
-		return
+	return written, nil
+}
+
+// nfoUserFields are the fields Kodi or the user change, they are kept when the NFO is re-written
+type nfoUserFields struct {
+	UserRating string `xml:"userrating"`
+	Watched    string `xml:"watched"`
+	PlayCount  string `xml:"playcount"`
+	UserNote   string `xml:"user_note"`
+}
+
+// keepUserFields reads the user fields from the existing NFO and puts them on the episode.
+// Not strict, as older files can have unescaped characters in them
+func keepUserFields(existing []byte, episode *models.NFOEpisodeDetails) {
+	var fields nfoUserFields
+
+	decoder := xml.NewDecoder(bytes.NewReader(existing))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	if err := decoder.Decode(&fields); err != nil {
+		log.Print("Could not parse the existing NFO, looking for the user fields one by one: ", err)
+		fields = nfoUserFields{
+			UserRating: nfoElement(existing, "userrating"),
+			Watched:    nfoElement(existing, "watched"),
+			PlayCount:  nfoElement(existing, "playcount"),
+			UserNote:   nfoElement(existing, "user_note"),
+		}
 	}
 
-	log.Printf(".nfi file created successfully: %s", filename)
+	episode.UserRating = fields.UserRating
+	episode.UserNote = fields.UserNote
+	if fields.Watched != "" {
+		episode.Watched = fields.Watched
+	}
+	if fields.PlayCount != "" {
+		episode.PlayCount = fields.PlayCount
+	}
+}
+
+// nfoElement finds the text of a single element, for NFO files that are not valid XML. The text is unescaped, the
+// templates escape it again
+func nfoElement(existing []byte, name string) string {
+	re := regexp.MustCompile(`(?s)<` + name + `>(.*?)</` + name + `>`)
+	matches := re.FindSubmatch(existing)
+	if matches == nil {
+		return ""
+	}
+	return html.UnescapeString(string(matches[1]))
+}
+
+// likeRating turns the likes into a rating out of 10. YouTube does not have dislikes anymore,
+// so the share of the viewers that liked the video is used, where 10% or more is a 10
+func likeRating(video models.Video) string {
+	if video.ViewCount == 0 {
+		return "0.0"
+	}
+	rating := min(float64(video.LikeCount)/float64(video.ViewCount)*100, 10)
+	return strconv.FormatFloat(rating, 'f', 1, 64)
 }
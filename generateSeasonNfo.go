@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"text/template"
+
+	"download-youtube/models"
+)
+
+// Seasons creates the season.nfo and poster for every season folder, re-written when the episodes have changed
+func (d Download) Seasons(videos []models.Video) {
+	show := d.show()
+
+	seasons := make(map[string][]models.Video)
+	for _, video := range videos {
+		if video.Season == "" {
+			continue
+		}
+		seasons[video.Season] = append(seasons[video.Season], video)
+	}
+
+	for season, episodes := range seasons {
+		sort.Slice(episodes, func(i, j int) bool {
+			// Numbers above 99 have more digits, compared as text "100" would come before "99"
+			episodeI, _ := strconv.Atoi(episodes[i].Episode)
+			episodeJ, _ := strconv.Atoi(episodes[j].Episode)
+			return episodeI < episodeJ
+		})
+
+		seasonPath := fmt.Sprintf("%s%s/Season %s", d.SaveLoc, d.ShowName, season)
+		if err := d.checkSeasonFolderExist(season); err != nil {
+			log.Print(err)
+			continue
+		}
+
+		generateSeasonNfo(season, earliestYear(episodes), seasonPath, episodes, show, d.Templates.Season)
+		seasonPoster(episodes, seasonPath)
+	}
+}
+
+// generateSeasonNfo creates the season.nfo with the season number and the year as title
+func generateSeasonNfo(season, year, seasonPath string, episodes []models.Video, show models.Show, tmpl *template.Template) {
+	seasonNumber, err := strconv.Atoi(season)
+	if err != nil {
+		log.Printf("invalid season %q: %v", season, err)
+		return
+	}
+
+	details := models.NFOSeasonDetails{
+		Title:        year,
+		Year:         year,
+		SeasonNumber: strconv.Itoa(seasonNumber),
+	}
+	if details.Title == "" {
+		details.Title = fmt.Sprintf("Season %s", season)
+	}
+
+	filename := fmt.Sprintf("%s/season.nfo", seasonPath)
+
+	data := seasonTemplateData{NFOSeasonDetails: details, Episodes: episodes, Show: show}
+	written, err := writeIfChanged(tmpl, data, filename)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if !written {
+		return
+	}
+
+	log.Printf("season.nfo file created successfully: %s", filename)
+}
+
+// seasonPoster uses the thumbnail of the first episode in the season as the season poster
+func seasonPoster(episodes []models.Video, seasonPath string) {
+	for _, episode := range episodes {
+		if !episode.ImageSaved {
+			continue
+		}
+
+		thumb, err := os.ReadFile(fmt.Sprintf("%s-thumb.jpg", episode.Filepath))
+		if err != nil {
+			log.Print("Error reading thumbnail:", err)
+			continue
+		}
+
+		filename := fmt.Sprintf("%s/poster.jpg", seasonPath)
+
+		existing, err := os.ReadFile(filename)
+		if err == nil && bytes.Equal(existing, thumb) {
+			return
+		}
+
+		err = os.WriteFile(filename, thumb, 0644)
+		if err != nil {
+			log.Print("Error writing season poster:", err)
+			return
+		}
+
+		log.Printf("Season poster created successfully: %s", filename)
+		return
+	}
+}
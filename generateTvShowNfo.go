@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/template"
+
+	"download-youtube/models"
+)
+
+// TvShowNfo creates the tvshow.nfo in the root of the show folder based on the channel data.
+// The channel data is saved so the other NFO templates can use it too
+func (d Download) TvShowNfo(channel models.Channel) {
+	if err := d.writeChannel(channel); err != nil {
+		log.Print("Problem with writting channel info: ", err)
+	}
+
+	videos, err := d.Store.Load()
+	if err != nil {
+		log.Print("Error reading video data:", err)
+	}
+
+	showPath := fmt.Sprintf("%s%s", d.SaveLoc, d.ShowName)
+	if err := os.MkdirAll(showPath, 0755); err != nil {
+		log.Print("Error creating show folder:", err)
+		return
+	}
+
+	show := models.Show{Name: d.ShowName, Channel: channel}
+	generateTvShowNfo(show, earliestYear(videos), showPath, d.Templates.TvShow)
+}
+
+// generateTvShowNfo creates the tvshow.nfo file, it is only re-written when the channel data has changed
+func generateTvShowNfo(show models.Show, year, showPath string, tmpl *template.Template) {
+	channel, showName := show.Channel, show.Name
+
+	// Define the show details
+	details := models.NFOTvShowDetails{
+		Title:          showName,
+		OriginalTitle:  channel.Title,
+		ShowTitle:      showName,
+		SortTitle:      showName,
+		Year:           year,
+		Plot:           channel.Description,
+		Premiered:      channel.PublishedAt,
+		Status:         "Continuing",
+		Watched:        "false",
+		PlayCount:      "0",
+		Studio:         "YouTube",
+		Country:        channel.Country,
+		ActorName:      channel.Title,
+		ActorRole:      "Creator",
+		ActorThumbnail: channel.AvatarURL,
+	}
+
+	filename := fmt.Sprintf("%s/tvshow.nfo", showPath)
+
+	written, err := writeIfChanged(tmpl, tvShowTemplateData{NFOTvShowDetails: details, Show: show}, filename)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	if !written {
+		log.Print("tvshow.nfo is up to date, skipping")
+		return
+	}
+
+	log.Printf("tvshow.nfo file created successfully: %s", filename)
+}
+
+// earliestYear finds the year of the oldest published video
+func earliestYear(videos []models.Video) string {
+	var year string
+	for _, video := range videos {
+		if len(video.PublishedAt) < 4 {
+			continue
+		}
+		if year == "" || video.PublishedAt[0:4] < year {
+			year = video.PublishedAt[0:4]
+		}
+	}
+	return year
+}
@@ -0,0 +1,175 @@
+package getYTData
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"download-youtube/models"
+)
+
+// APICache keeps the responses of the YouTube Data API on disk, keyed by the URL without the API key. A response
+// younger than TTL is used as it is, an older one is sent with If-None-Match and used again when YouTube answers
+// 304 Not Modified. A nil cache fetches everything
+type APICache struct {
+	Dir string
+	TTL time.Duration
+}
+
+// cacheMaxAge is how long a response is kept in the cache without being used, or 10 times TTL when that is longer.
+// Search URLs have the time of the last sync in them, so most of them are only used once
+const cacheMaxAge = 7 * 24 * time.Hour
+
+// cachedResponse is a response saved in the cache
+type cachedResponse struct {
+	URL       string          `json:"url"`
+	ETag      string          `json:"etag"`
+	FetchedAt time.Time       `json:"fetchedAt"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// fetch returns the body of a 200 OK response to the URL, from the cache when possible. Requests that are needed
+// are made with send
+func (c *APICache) fetch(apiURL string, send func(req *http.Request) (*http.Response, error)) ([]byte, error) {
+	cached, found := c.load(apiURL)
+	if found && time.Since(cached.FetchedAt) < c.TTL {
+		return cached.Body, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if found && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := send(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching URL %s: %w", apiURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response from %s: %v", apiURL, err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && found {
+		cached.FetchedAt = time.Now()
+		c.save(apiURL, cached)
+		return cached.Body, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, apiURL, body)
+	}
+
+	c.save(apiURL, cachedResponse{ETag: responseETag(resp, body), FetchedAt: time.Now(), Body: body})
+	return body, nil
+}
+
+func (c *APICache) load(apiURL string) (cachedResponse, bool) {
+	var cached cachedResponse
+	if c == nil {
+		return cached, false
+	}
+
+	cachedByte, err := os.ReadFile(c.path(apiURL))
+	if err != nil {
+		return cached, false
+	}
+	// A broken file is fetched again and replaced
+	if err := json.Unmarshal(cachedByte, &cached); err != nil || cached.URL != cacheKey(apiURL) {
+		return cached, false
+	}
+
+	return cached, true
+}
+
+// save writes the response to the cache, a response that can not be saved is only logged
+func (c *APICache) save(apiURL string, cached cachedResponse) {
+	if c == nil {
+		return
+	}
+	cached.URL = cacheKey(apiURL)
+
+	cachedByte, err := json.Marshal(cached)
+	if err == nil {
+		err = os.MkdirAll(c.Dir, 0755)
+	}
+	if err == nil {
+		err = models.WriteState(c.path(apiURL), cachedByte, 0)
+	}
+	if err != nil {
+		log.Printf("Problem caching the response of %s: %v", cached.URL, err)
+	}
+}
+
+// Prune removes the responses that have not been used for cacheMaxAge, returns how many were removed
+func (c *APICache) Prune() int {
+	if c == nil {
+		return 0
+	}
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return 0
+	}
+
+	maxAge := max(cacheMaxAge, 10*c.TTL)
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || filepath.Ext(entry.Name()) != ".json" || time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.Dir, entry.Name())); err != nil {
+			log.Printf("Problem removing an old cached response: %v", err)
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// path is the file of the URL, named after a hash of the key
+func (c *APICache) path(apiURL string) string {
+	hash := sha256.Sum256([]byte(cacheKey(apiURL)))
+	return filepath.Join(c.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+// cacheKey is the URL without the API key, so a new key still uses the cache and the key is never saved
+func cacheKey(apiURL string) string {
+	parsed, err := url.Parse(apiURL)
+	if err != nil {
+		return apiURL
+	}
+
+	query := parsed.Query()
+	query.Del("key")
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// responseETag is the ETag header, or the etag every Data API response has in the body
+func responseETag(resp *http.Response, body []byte) string {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag
+	}
+
+	var res struct {
+		ETag string `json:"etag"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil || res.ETag == "" {
+		return ""
+	}
+	return `"` + res.ETag + `"`
+}
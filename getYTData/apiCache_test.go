@@ -0,0 +1,161 @@
+package getYTData
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// etagServer answers with the body and ETag, and 304 Not Modified when the request has the ETag
+type etagServer struct {
+	body     string
+	etag     string
+	requests []*http.Request
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests = append(s.requests, r)
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.body))
+}
+
+func sendDirect(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+func TestAPICacheNotModified(t *testing.T) {
+	server := &etagServer{body: `{"items":[1]}`, etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cache := &APICache{Dir: t.TempDir(), TTL: time.Hour}
+	apiURL := ts.URL + "/youtube/v3/videos?id=abc&key=secret"
+
+	for i := 0; i < 2; i++ {
+		body, err := cache.fetch(apiURL, sendDirect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != server.body {
+			t.Errorf("fetch %d = %s, want %s", i, body, server.body)
+		}
+	}
+	if len(server.requests) != 1 {
+		t.Fatalf("%d requests within the TTL, want 1", len(server.requests))
+	}
+
+	cache.TTL = 0
+	body, err := cache.fetch(apiURL, sendDirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != server.body {
+		t.Errorf("after 304 = %s, want %s", body, server.body)
+	}
+	if len(server.requests) != 2 {
+		t.Fatalf("%d requests after the TTL, want 2", len(server.requests))
+	}
+	if got := server.requests[1].Header.Get("If-None-Match"); got != server.etag {
+		t.Errorf("If-None-Match = %q, want %q", got, server.etag)
+	}
+
+	server.body, server.etag = `{"items":[2]}`, `"v2"`
+	body, err = cache.fetch(apiURL, sendDirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != server.body {
+		t.Errorf("after a change = %s, want %s", body, server.body)
+	}
+}
+
+func TestAPICacheError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":500,"message":"backend error"}}`, http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	cache := &APICache{Dir: t.TempDir(), TTL: time.Hour}
+	if _, err := cache.fetch(ts.URL+"/youtube/v3/videos?id=abc", sendDirect); err == nil {
+		t.Fatal("error response was returned as a body")
+	}
+	if entries, _ := os.ReadDir(cache.Dir); len(entries) != 0 {
+		t.Errorf("error response was cached: %d files", len(entries))
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.googleapis.com/youtube/v3/videos?key=secret&id=abc", "https://www.googleapis.com/youtube/v3/videos?id=abc"},
+		{"https://www.googleapis.com/youtube/v3/videos?id=abc&key=secret&part=snippet", "https://www.googleapis.com/youtube/v3/videos?id=abc&part=snippet"},
+		{"https://www.googleapis.com/youtube/v3/videos?part=snippet&id=abc", "https://www.googleapis.com/youtube/v3/videos?id=abc&part=snippet"},
+		{"https://www.googleapis.com/youtube/v3/channels", "https://www.googleapis.com/youtube/v3/channels"},
+	}
+
+	for _, test := range tests {
+		if got := cacheKey(test.url); got != test.want {
+			t.Errorf("cacheKey(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestAPICacheWithoutKey(t *testing.T) {
+	server := &etagServer{body: `{"items":[]}`, etag: `"v1"`}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cache := &APICache{Dir: t.TempDir(), TTL: time.Hour}
+	for _, key := range []string{"first-secret", "second-secret"} {
+		if _, err := cache.fetch(ts.URL+"/youtube/v3/videos?id=abc&key="+key, sendDirect); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(server.requests) != 1 {
+		t.Errorf("%d requests with a new key, want 1", len(server.requests))
+	}
+
+	files, _ := filepath.Glob(filepath.Join(cache.Dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("%d cache files, want 1", len(files))
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "secret") {
+		t.Errorf("API key saved in the cache: %s", content)
+	}
+}
+
+func TestAPICachePrune(t *testing.T) {
+	cache := &APICache{Dir: t.TempDir(), TTL: time.Hour}
+	cache.save("https://www.googleapis.com/youtube/v3/search?publishedAfter=old", cachedResponse{Body: []byte(`{}`)})
+	cache.save("https://www.googleapis.com/youtube/v3/search?publishedAfter=new", cachedResponse{Body: []byte(`{}`)})
+
+	old := cache.path("https://www.googleapis.com/youtube/v3/search?publishedAfter=old")
+	unused := time.Now().Add(-cacheMaxAge - time.Hour)
+	if err := os.Chtimes(old, unused, unused); err != nil {
+		t.Fatal(err)
+	}
+
+	if removed := cache.Prune(); removed != 1 {
+		t.Errorf("pruned %d, want 1", removed)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("unused response was kept: %v", err)
+	}
+	if _, found := cache.load("https://www.googleapis.com/youtube/v3/search?publishedAfter=new"); !found {
+		t.Error("recent response was removed")
+	}
+}
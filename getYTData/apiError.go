@@ -0,0 +1,59 @@
+package getYTData
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"download-youtube/models"
+)
+
+// APIError is returned when the YouTube Data API answers with anything but 200 OK
+type APIError struct {
+	StatusCode int
+	Reason     string
+	Message    string
+	URL        string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("received status code %d for URL %s: %s %s", e.StatusCode, e.URL, e.Reason, e.Message)
+}
+
+// Unwrap maps the error reason to the sentinel errors, so callers can check it with errors.Is
+func (e *APIError) Unwrap() error {
+	switch e.Reason {
+	case "quotaExceeded", "dailyLimitExceeded":
+		return models.ErrQuotaExceeded
+	case "rateLimitExceeded", "userRateLimitExceeded":
+		return models.ErrRateLimited
+	}
+	if e.StatusCode == 429 {
+		return models.ErrRateLimited
+	}
+	return nil
+}
+
+// errorResponse is the error body the YouTube Data API sends back
+type errorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Errors  []struct {
+			Reason string `json:"reason"`
+		} `json:"errors"`
+	} `json:"error"`
+}
+
+func newAPIError(statusCode int, url string, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, URL: url, Message: string(body)}
+
+	var res errorResponse
+	if err := json.Unmarshal(body, &res); err == nil {
+		apiErr.Message = res.Error.Message
+		if len(res.Error.Errors) > 0 {
+			apiErr.Reason = res.Error.Errors[0].Reason
+		}
+	}
+
+	return apiErr
+}
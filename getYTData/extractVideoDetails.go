@@ -1,74 +1,211 @@
 package getYTData
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"net/url"
 	"os"
-	"regexp"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"download-youtube/models"
+	"download-youtube/stateStore"
 )
 
 type YouTubeChannel struct {
-	JsonFilePath        string
+	Store stateStore.Store
+	// Full pages through every video instead of stopping at the first page of known ones
+	Full bool
+	// Cache keeps the API responses, nil fetches everything
+	Cache *APICache
+	// Quota counts the units used by the API calls, nil does not count them
+	Quota               *QuotaTracker
 	EnvVar              models.EnvVar
 	CurrentVideoData    []models.Video
 	DownloadedVideoData []models.Video
 }
 
-// get gets all video data based on the channel ID. Will loop until it has recieved all of them or reached the maxResult
-func (YT YouTubeChannel) GetData() {
-	var existingVideos []models.Video
+// GetData gets all video data based on the channel ID. Will loop until it has recieved all of them or reached the maxResult
+func (YT YouTubeChannel) GetData() error {
 	var extractedInfo []models.Video
+	syncStarted := time.Now().UTC()
 
-	jsonFile, err := os.Open(YT.JsonFilePath)
+	existingVideos, err := YT.Store.Load()
 	if err != nil {
-		log.Print("Did not find any data for: ", YT.JsonFilePath)
-		log.Print("Will generate new file with data")
-	} else {
-		log.Print("Reading Existing File")
-		jsonByte, _ := io.ReadAll(jsonFile)
-		json.Unmarshal(jsonByte, &existingVideos)
+		return err
+	}
+	if len(existingVideos) == 0 {
+		log.Print("Did not find any saved videos, will save all of them")
+	}
+	existingVideos = BackfillIDs(existingVideos)
+
+	if pruned := YT.Cache.Prune(); pruned > 0 {
+		log.Printf("Removed %d unused responses from the API cache", pruned)
 	}
-	defer jsonFile.Close()
 
-	if YT.EnvVar.ChannelID != "" {
-		newVideoData, err := YT.GetSearchResultVideos()
+	saved := make(map[string]bool, len(existingVideos))
+	for _, video := range existingVideos {
+		if video.ID != "" {
+			saved[video.ID] = true
+		}
+	}
+
+	// Without a saved sync, or with Full, every page is walked. Videos saved by an older version can be missing
+	// the older uploads, e.g. when search stopped at around 500 videos
+	var known map[string]bool
+	var publishedAfter time.Time
+	if !YT.Full && len(saved) > 0 {
+		lastSync, err := YT.Store.LastSync()
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		if lastSync.IsZero() {
+			log.Print("No earlier sync saved, walking every page")
+		} else {
+			known = saved
+			// A day of overlap for videos that become public after they were published. With playlist seasons the
+			// videos still waiting for a playlist are searched again as well
+			overlap := syncOverlap
+			if YT.EnvVar.SeasonStrategy == models.SeasonsByPlaylist {
+				overlap += playlistWait
+			}
+			publishedAfter = lastSync.Add(-overlap)
+		}
+	}
+
+	var searchResults []SearchResult
+	var playlistItems []PlaylistItem
+	if YT.EnvVar.ChannelID != "" && YT.EnvVar.FetchMode == models.FetchSearch {
+		searchResults, err = YT.GetSearchResultVideos(known, publishedAfter)
+		if err != nil {
+			return err
 		}
 
 		// newVideoData, err := YT.getVideosDEBUG()
 		// if err != nil {
-		// 	log.Fatal(err)
+		// 	return err
 		// }
 
-		extractedInfo = YT.ExtractSearchResultInfo(newVideoData)
+	} else if YT.EnvVar.ChannelID != "" {
+		// The uploads playlist has the complete history of the channel, search stops at around 500 videos
+		uploadsPlaylistID, err := YT.uploadsPlaylistID()
+		if err != nil {
+			return err
+		}
 
-	} else if YT.EnvVar.PlaylistID != "" {
-		newVideoData, err := YT.GetPlaylistSearchResultVideos()
+		playlistItems, err = YT.GetPlaylistSearchResultVideos(uploadsPlaylistID, known)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
-		extractedInfo = YT.ExtractPlaylistSearchResultInfo(newVideoData)
+	} else if YT.EnvVar.PlaylistID != "" {
+		// Only the uploads playlist and search are newest first. A playlist is in the order of its owner, usually
+		// with new videos added at the end, so every page is walked
+		playlistItems, err = YT.GetPlaylistSearchResultVideos(YT.EnvVar.PlaylistID, nil)
+		if err != nil {
+			return err
+		}
 
 	} else {
-		log.Fatal("Neither ChannelID or Playlist ID has values")
+		return fmt.Errorf("neither ChannelID or Playlist ID has values")
+	}
+
+	// Only the videos not saved yet need a season, the saved ones keep theirs
+	unsaved := make(map[string]bool)
+	for _, item := range searchResults {
+		if item.ID.VideoID != "" && !saved[item.ID.VideoID] {
+			unsaved[item.ID.VideoID] = true
+		}
+	}
+	for _, item := range playlistItems {
+		if item.Snippet.ResourceID.VideoID != "" && !saved[item.Snippet.ResourceID.VideoID] {
+			unsaved[item.Snippet.ResourceID.VideoID] = true
+		}
+	}
+
+	seasons, err := YT.seasonStrategy(unsaved)
+	if err != nil {
+		return err
+	}
+	extractedInfo = append(YT.ExtractSearchResultInfo(searchResults, seasons), YT.ExtractPlaylistSearchResultInfo(playlistItems, seasons)...)
+
+	if changed := UpdateMetadata(existingVideos, extractedInfo); changed > 0 {
+		log.Printf("Metadata changed for %d videos", changed)
+	}
+	if renamed := YT.RefreshFilePaths(existingVideos); renamed > 0 {
+		log.Printf("New file names for %d videos not downloaded yet", renamed)
 	}
 
 	videosToAdd := FindNewVideos(existingVideos, extractedInfo)
+	NumberEpisodes(existingVideos, videosToAdd)
+	for i := range videosToAdd {
+		videosToAdd[i] = YT.FilePathAndName(videosToAdd[i])
+	}
 	existingVideos = append(existingVideos, videosToAdd...)
 
-	marshalled, _ := json.Marshal(existingVideos)
-	err = os.WriteFile(YT.JsonFilePath, marshalled, 0644)
+	// The new videos and the ones saved before the details were added
+	enrichErr := YT.enrichMissingDetails(existingVideos)
+
+	if err := YT.Store.Save(existingVideos); err != nil {
+		return fmt.Errorf("problem with saving the videos: %w", err)
+	}
+	log.Printf("Successfully saved %d videos", len(existingVideos))
+
+	if err := YT.Store.SetLastSync(syncStarted); err != nil {
+		log.Printf("Error saving the time of the sync: %v", err)
+	}
+
+	return enrichErr
+}
+
+// detailsRetry is how long after it was published a video without a duration, e.g. an upcoming premiere or a live
+// stream, gets its details fetched again, at most once a day
+const detailsRetry = 7 * 24 * time.Hour
+
+// enrichMissingDetails adds the video details to the videos that don't have them yet, in place
+func (YT YouTubeChannel) enrichMissingDetails(videos []models.Video) error {
+	var missing []models.Video
+	var indexes []int
+	for i, video := range videos {
+		if needsDetails(video, time.Now()) {
+			missing = append(missing, video)
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	enriched, err := YT.EnrichVideos(missing)
+	for i, index := range indexes {
+		videos[index] = enriched[i]
+	}
+
+	return err
+}
+
+// needsDetails checks if the details of the video were never fetched. Without a duration they are fetched again
+// for a while, as YouTube only has it once a premiere or live stream is over
+func needsDetails(video models.Video, now time.Time) bool {
+	if video.ID == "" || video.Duration != 0 {
+		return false
+	}
+	if video.DetailsFetchedAt == "" {
+		return true
+	}
+
+	fetched, err := time.Parse(time.RFC3339, video.DetailsFetchedAt)
 	if err != nil {
-		log.Print("Problem with writting JSON", err)
+		return true
 	}
-	log.Printf("Successfully saved the JSON file to: %s", YT.JsonFilePath)
+	published, err := time.Parse(time.RFC3339, video.PublishedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(published) < detailsRetry && now.Sub(fetched) > 24*time.Hour
 }
 
 const (
@@ -78,19 +215,37 @@ const (
 	defaultPart       = "snippet,id"
 	defaultOrder      = "date"
 	defaultMaxResults = 50
+	// maxSearchPages is where search stops, YouTube does not return more than around 500 results anyway
+	maxSearchPages = 50
+	// syncOverlap is how far before the last sync search looks again
+	syncOverlap = 24 * time.Hour
 )
 
+// onlyKnown checks that every video of a page is already saved, so the pages after it are as well
+func onlyKnown(known map[string]bool, ids []string) bool {
+	if known == nil || len(ids) == 0 {
+		return false
+	}
+	for _, id := range ids {
+		if !known[id] {
+			return false
+		}
+	}
+	return true
+}
+
 // buildYouTubeURL constructs the API URL for either search or playlistItems endpoint.
-func (YT YouTubeChannel) buildURL(pageToken string) (string, error) {
+// An empty playlistID searches the channel
+func (YT YouTubeChannel) buildURL(playlistID, pageToken string) (string, error) {
 	var endpoint, idParam, idValue string
-	if YT.EnvVar.ChannelID != "" {
+	if playlistID == "" {
 		endpoint = searchEndpoint
 		idParam = "channelId"
 		idValue = YT.EnvVar.ChannelID
 	} else {
 		endpoint = playlistEndpoint
 		idParam = "playlistId"
-		idValue = YT.EnvVar.PlaylistID
+		idValue = playlistID
 	}
 
 	return fmt.Sprintf("%s/%s?key=%s&%s=%s&part=%s&order=%s&maxResults=%d&pageToken=%s",
@@ -102,66 +257,196 @@ func normalizeTitle(title string) string {
 	return strings.TrimSpace(strings.ToLower(title))
 }
 
-// FindNewVideos compares new videos against existing ones and returns new ones
+// FindNewVideos compares new videos against existing ones by video ID and returns new ones.
+// Existing videos without an ID, that could not be backfilled, are still matched by title
 func FindNewVideos(existing, newVideos []models.Video) []models.Video {
-	// Build a map of existing titles for O(1) lookups
+	// Build a map of existing IDs for O(1) lookups
+	idMap := make(map[string]struct{})
 	titleMap := make(map[string]struct{})
 	for _, video := range existing {
-		titleMap[normalizeTitle(video.Title)] = struct{}{}
+		if video.ID != "" {
+			idMap[video.ID] = struct{}{}
+		} else {
+			titleMap[normalizeTitle(video.Title)] = struct{}{}
+		}
 	}
 
 	// Collect new videos that don't exist in the map
 	var videosToAdd []models.Video
 	for _, video := range newVideos {
-		log.Printf("Does %s exist already?", video.Title)
-		if _, exists := titleMap[normalizeTitle(video.Title)]; !exists {
-			log.Printf("%s Does NOT exist, lets add it!", video.Title)
-			videosToAdd = append(videosToAdd, video)
+		log.Printf("Does %s (%s) exist already?", video.Title, video.ID)
+		if _, exists := idMap[video.ID]; exists {
+			continue
+		}
+		if _, exists := titleMap[normalizeTitle(video.Title)]; exists {
+			continue
 		}
+
+		log.Printf("%s Does NOT exist, lets add it!", video.Title)
+		idMap[video.ID] = struct{}{}
+		videosToAdd = append(videosToAdd, video)
 	}
 	return videosToAdd
 }
 
-// normalizeTitle normalizes special characters in the title
-func normalizeYouTubeTitle(input string) string {
-	// Replace & with "and"
-	input = strings.ReplaceAll(input, "&", "and")
-	input = strings.ReplaceAll(input, " l ", "|")
+// BackfillIDs sets the ID of videos saved before it was stored, from the v= parameter of their URL. A video that was
+// retitled was saved again under the new title, those are merged into one, see mergeDuplicates
+func BackfillIDs(videos []models.Video) []models.Video {
+	for i, video := range videos {
+		if video.ID != "" {
+			continue
+		}
+		if id := videoIDFromURL(video.URL); id != "" {
+			videos[i].ID = id
+			log.Printf("Backfilled ID %s for: %s", id, video.Title)
+		}
+	}
 
-	return input
+	return mergeDuplicates(videos)
 }
 
-func extractEpisodeInfo(input string) (string, string, string, error) {
-	log.Print("Input: ", input)
-	normalizedInput := normalizeYouTubeTitle(input)
+// mergeDuplicates keeps one video for every ID, the first downloaded one or else the oldest saved. The entries saved
+// later have the newer title, the video kept gets the newest one and the titles before it are recorded as changes
+func mergeDuplicates(videos []models.Video) []models.Video {
+	keep := make(map[string]int)
+	for i, video := range videos {
+		if video.ID == "" {
+			continue
+		}
+		kept, found := keep[video.ID]
+		if !found || (video.Downloaded && !videos[kept].Downloaded) {
+			keep[video.ID] = i
+		}
+	}
+
+	detectedAt := time.Now().UTC().Format(time.RFC3339)
+	merged := make([]models.Video, 0, len(videos))
+	mergedIndex := make(map[string]int)
+	for i, video := range videos {
+		if kept, found := keep[video.ID]; found && kept != i {
+			continue
+		}
+		if video.ID != "" {
+			mergedIndex[video.ID] = len(merged)
+		}
+		merged = append(merged, video)
+	}
+
+	duplicated := make(map[string]bool)
+	// titles is the title of the entry of the ID saved last so far
+	titles := make(map[string]string)
+	for i, video := range videos {
+		kept, found := keep[video.ID]
+		if !found {
+			continue
+		}
+
+		target := &merged[mergedIndex[video.ID]]
+		if previous, seen := titles[video.ID]; seen && video.Title != previous {
+			change := models.MetadataChange{Field: "title", Old: previous, New: video.Title, DetectedAt: detectedAt}
+			target.Changes = append(target.Changes, change)
+		}
+		titles[video.ID] = video.Title
+		if kept == i {
+			continue
+		}
 
-	log.Print("normalizedInput: ", normalizedInput)
-	// Define the regex pattern to match the title, episode, and season (case-insensitive for EPISODE and Season)
-	// Assumes format: "Title | ... EPISODE <number> | Season <number>"
-	pattern := `^(.*?)\s*\|\s*.*[Ee][Pp][Ii][Ss][Oo][Dd][Ee]\s+(\d+)\s*\|\s*[Ss][Ee][Aa][Ss][Oo][Nn]\s+(\d+)`
-	re := regexp.MustCompile(pattern)
+		duplicated[video.ID] = true
+		target.Changes = append(target.Changes, video.Changes...)
 
-	// Find matches
-	matches := re.FindStringSubmatch(normalizedInput)
-	if len(matches) != 4 {
-		return "", "", "", fmt.Errorf("invalid format: %s", input)
+		log.Printf("Merged duplicate of %s: %s", video.ID, video.Title)
+		if video.Downloaded {
+			log.Printf("Files of the duplicate are not used anymore: %s", video.Filepath)
+		}
+	}
+
+	for id := range duplicated {
+		target := &merged[mergedIndex[id]]
+		target.Title = titles[id]
+		// The changes of every entry are kept oldest first
+		sort.SliceStable(target.Changes, func(i, j int) bool {
+			return target.Changes[i].DetectedAt < target.Changes[j].DetectedAt
+		})
 	}
 
-	title := strings.TrimSpace(matches[1])
-	episodeStr := matches[2]
-	seasonStr := matches[3]
+	return merged
+}
 
-	// Add leading zero to season and episode if needed
-	season := seasonStr
-	if len(seasonStr) == 1 {
-		season = "0" + seasonStr
+// videoIDFromURL reads the video ID from a watch or youtu.be URL
+func videoIDFromURL(videoURL string) string {
+	parsed, err := url.Parse(videoURL)
+	if err != nil {
+		return ""
+	}
+	if id := parsed.Query().Get("v"); id != "" {
+		return id
 	}
-	episode := episodeStr
-	if len(episodeStr) == 1 {
-		episode = "0" + episodeStr
+	if parsed.Host == "youtu.be" {
+		return strings.Trim(parsed.Path, "/")
 	}
+	return ""
+}
+
+// UpdateMetadata updates the title, description and thumbnail of the existing videos that changed on YouTube,
+// in place, and records the old value. A new thumbnail is downloaded again. Returns how many videos changed
+func UpdateMetadata(existing, fetched []models.Video) int {
+	fetchedByID := make(map[string]models.Video)
+	for _, video := range fetched {
+		fetchedByID[video.ID] = video
+	}
+
+	detectedAt := time.Now().UTC().Format(time.RFC3339)
+	var changed int
+	for i, video := range existing {
+		latest, found := fetchedByID[video.ID]
+		if video.ID == "" || !found {
+			continue
+		}
 
-	return title, season, episode, nil
+		changes := metadataChanges(video, latest, detectedAt)
+		if len(changes) == 0 {
+			continue
+		}
+
+		for _, change := range changes {
+			log.Printf("%s changed for %s: %q -> %q", change.Field, video.ID, change.Old, change.New)
+			switch change.Field {
+			case "title":
+				existing[i].Title = latest.Title
+			case "description":
+				existing[i].Description = latest.Description
+			case "thumbnail":
+				existing[i].ThumbnailURL = latest.ThumbnailURL
+				existing[i].ImageSaved = false
+			}
+		}
+		existing[i].Changes = append(existing[i].Changes, changes...)
+		changed++
+	}
+	return changed
+}
+
+// metadataChanges compares the saved video with the one from YouTube
+func metadataChanges(saved, latest models.Video, detectedAt string) []models.MetadataChange {
+	var changes []models.MetadataChange
+	add := func(field, before, after string) {
+		if before != after && after != "" {
+			changes = append(changes, models.MetadataChange{Field: field, Old: before, New: after, DetectedAt: detectedAt})
+		}
+	}
+
+	add("title", saved.Title, latest.Title)
+	add("description", saved.Description, latest.Description)
+	add("thumbnail", saved.ThumbnailURL, latest.ThumbnailURL)
+
+	return changes
+}
+
+// normalizeTitle normalizes special characters in the title
+func normalizeYouTubeTitle(input string) string {
+	input = strings.ReplaceAll(input, " l ", "|")
+
+	return input
 }
 
 // getThumbUrl looks for the biggest thumbnail and saves that as the best option for Thumbnail URL
@@ -188,12 +473,50 @@ func printData(video models.Video) {
 	log.Print()
 }
 
+// RefreshFilePaths names the saved videos that are not downloaded yet again, in place, so the ones saved before the
+// file names were sanitized don't download into broken paths. A saved thumbnail and the streams of an unfinished
+// download are moved along. Returns how many videos got a new name
+func (YT YouTubeChannel) RefreshFilePaths(videos []models.Video) int {
+	var renamed int
+	for i, video := range videos {
+		if video.Downloaded {
+			continue
+		}
+
+		named := YT.FilePathAndName(video)
+		if named.Filepath == video.Filepath {
+			continue
+		}
+
+		err := os.MkdirAll(filepath.Dir(named.Filepath), 0755)
+		if err == nil {
+			err = models.MoveEpisodeFiles(video.Filepath, named.Filepath)
+		}
+		if err != nil {
+			log.Printf("Problem moving the files of %s, keeping the old name: %v", video.Filepath, err)
+			_ = models.MoveEpisodeFiles(named.Filepath, video.Filepath)
+			continue
+		}
+		if video.ImageSaved {
+			if _, err := os.Stat(named.Filepath + "-thumb.jpg"); err != nil {
+				log.Printf("Thumbnail of %s is missing, downloading it again", named.Filepath)
+				named.ImageSaved = false
+			}
+		}
+		log.Printf("Renamed %s -> %s", video.Filepath, named.Filepath)
+
+		videos[i] = named
+		renamed++
+	}
+	return renamed
+}
+
 func (YT YouTubeChannel) FilePathAndName(video models.Video) models.Video {
-	video.Filepath = fmt.Sprintf("%s%s/Season %s/S%sE%s - %s",
-		YT.EnvVar.SaveLoc, YT.EnvVar.ChannelName, video.Season, video.Season, video.Episode, video.Title)
+	seasonPath := fmt.Sprintf("%s%s/Season %s", YT.EnvVar.SaveLoc, YT.EnvVar.ChannelName, video.Season)
 
-	video.Filename = fmt.Sprintf("S%sE%s - %s",
-		video.Season, video.Episode, video.Title)
+	profile := models.FilenameProfile(YT.EnvVar.FilenameProfile)
+	video.Filename = profile.Filename(seasonPath, fmt.Sprintf("S%sE%s - %s", video.Season, video.Episode, video.Title))
+	video.Filepath = fmt.Sprintf("%s/%s", seasonPath, video.Filename)
 
 	return video
 }
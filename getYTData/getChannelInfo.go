@@ -0,0 +1,125 @@
+package getYTData
+
+import (
+	"download-youtube/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+)
+
+const (
+	channelsEndpoint  = "channels"
+	playlistsEndpoint = "playlists"
+)
+
+// GetChannelInfo gets the title, description and avatar of the channel, used for the show level NFO.
+// When only a playlist is configured, the channel owning the playlist is looked up first
+func (YT YouTubeChannel) GetChannelInfo() (models.Channel, error) {
+	var channel models.Channel
+
+	channelID := YT.EnvVar.ChannelID
+	if channelID == "" {
+		var err error
+		channelID, err = YT.playlistChannelID()
+		if err != nil {
+			return channel, err
+		}
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s&id=%s&part=snippet", baseURL, channelsEndpoint, YT.EnvVar.ApiKey, channelID)
+
+	var res ChannelListResponse
+	if err := YT.getJSON(url, &res); err != nil {
+		return channel, err
+	}
+
+	if len(res.Items) == 0 {
+		return channel, fmt.Errorf("no channel found with ID: %s", channelID)
+	}
+
+	item := res.Items[0]
+	channel.ID = item.ID
+	channel.Title = item.Snippet.Title
+	channel.Description = item.Snippet.Description
+	channel.PublishedAt = item.Snippet.PublishedAt
+	channel.Country = item.Snippet.Country
+	channel.AvatarURL = biggestThumbnail(item.Snippet.Thumbnails)
+
+	log.Printf("Found channel: %s", channel.Title)
+
+	return channel, nil
+}
+
+// uploadsPlaylistID looks up the playlist with every upload of the channel
+func (YT YouTubeChannel) uploadsPlaylistID() (string, error) {
+	url := fmt.Sprintf("%s/%s?key=%s&id=%s&part=contentDetails", baseURL, channelsEndpoint, YT.EnvVar.ApiKey, YT.EnvVar.ChannelID)
+
+	var res ChannelListResponse
+	if err := YT.getJSON(url, &res); err != nil {
+		return "", err
+	}
+
+	if len(res.Items) == 0 || res.Items[0].ContentDetails.RelatedPlaylists.Uploads == "" {
+		return "", fmt.Errorf("no uploads playlist found for channel ID: %s", YT.EnvVar.ChannelID)
+	}
+
+	log.Printf("Using uploads playlist: %s", res.Items[0].ContentDetails.RelatedPlaylists.Uploads)
+
+	return res.Items[0].ContentDetails.RelatedPlaylists.Uploads, nil
+}
+
+// playlistChannelID looks up which channel owns the configured playlist
+func (YT YouTubeChannel) playlistChannelID() (string, error) {
+	url := fmt.Sprintf("%s/%s?key=%s&id=%s&part=snippet", baseURL, playlistsEndpoint, YT.EnvVar.ApiKey, YT.EnvVar.PlaylistID)
+
+	var res PlaylistListResponse
+	if err := YT.getJSON(url, &res); err != nil {
+		return "", err
+	}
+
+	if len(res.Items) == 0 {
+		return "", fmt.Errorf("no playlist found with ID: %s", YT.EnvVar.PlaylistID)
+	}
+
+	return res.Items[0].Snippet.ChannelID, nil
+}
+
+// getJSON fetches the URL, through the cache, and decodes the JSON response into out
+func (YT YouTubeChannel) getJSON(url string, out any) error {
+	body, err := YT.Cache.fetch(url, YT.send)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && errors.Is(err, models.ErrQuotaExceeded) {
+		YT.Quota.Exhausted()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response from %s: %v", url, err)
+	}
+
+	return nil
+}
+
+// send makes a request to the API, counted against the quota
+func (YT YouTubeChannel) send(req *http.Request) (*http.Response, error) {
+	if err := YT.Quota.Spend(path.Base(req.URL.Path)); err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// biggestThumbnail picks the largest available thumbnail
+func biggestThumbnail(thumbnails Thumbnails) string {
+	return getThumbUrl(map[string]Thumbnail{
+		"default":  thumbnails.Default,
+		"medium":   thumbnails.Medium,
+		"high":     thumbnails.High,
+		"standard": thumbnails.Standard,
+		"maxres":   thumbnails.Maxres,
+	})
+}
@@ -2,54 +2,44 @@ package getYTData
 
 import (
 	"download-youtube/models"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func (YT YouTubeChannel) GetPlaylistSearchResultVideos() ([]PlaylistItem, error) {
+// GetPlaylistSearchResultVideos pages through the items of the playlist. Stops at the first page that only has
+// known videos, a nil known pages through everything
+func (YT YouTubeChannel) GetPlaylistSearchResultVideos(playlistID string, known map[string]bool) ([]PlaylistItem, error) {
 	nextPageToken := ""
-	totalFetched := 0
 
-	client := &http.Client{}
 	var videoData []PlaylistItem
 
 	for {
-		url, err := YT.buildURL(nextPageToken)
+		url, err := YT.buildURL(playlistID, nextPageToken)
 		if err != nil {
 			log.Printf("Error building URL: %v", err)
 			return videoData, err
 		}
 
-		resp, err := client.Get(url)
-		if err != nil {
-			log.Printf("Error fetching URL %s: %v", url, err)
-			return videoData, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			err := fmt.Errorf("received status code %d for URL %s: %s", resp.StatusCode, url, body)
-			log.Print(err)
-			return videoData, err
-		}
-
 		var res PlaylistItemListResponse
-		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-			log.Printf("Error decoding response from %s: %v", url, err)
+		if err := YT.getJSON(url, &res); err != nil {
+			log.Print(err)
 			return videoData, err
 		}
 
 		videoData = append(videoData, res.Items...)
-		totalFetched++
 
-		if res.NextPageToken == "" || totalFetched >= defaultMaxResults {
+		var ids []string
+		for _, item := range res.Items {
+			ids = append(ids, item.Snippet.ResourceID.VideoID)
+		}
+		if onlyKnown(known, ids) {
+			log.Print("Page only has known videos, stopping")
+			break
+		}
+
+		if res.NextPageToken == "" {
 			break
 		}
 
@@ -63,16 +53,7 @@ func (YT YouTubeChannel) GetPlaylistSearchResultVideos() ([]PlaylistItem, error)
 }
 
 // extractInformation takes the response JSON and saves it to our Video Struct
-func (YT YouTubeChannel) ExtractPlaylistSearchResultInfo(videoData []PlaylistItem) []models.Video {
-	var currentEpisode = 1
-	var currentSeason = 1
-
-	startYear, err := strconv.Atoi(YT.EnvVar.SeasonStartYear)
-	if err != nil {
-		log.Printf("invalid SEASON_START_YEAR %q: %v", YT.EnvVar.SeasonStartYear, err)
-		return nil
-	}
-
+func (YT YouTubeChannel) ExtractPlaylistSearchResultInfo(videoData []PlaylistItem, seasons SeasonStrategy) []models.Video {
 	var videosData []models.Video
 
 	for _, item := range videoData {
@@ -88,35 +69,12 @@ func (YT YouTubeChannel) ExtractPlaylistSearchResultInfo(videoData []PlaylistIte
 		video.URL = fmt.Sprintf("https://www.youtube.com/watch?v=%s", item.Snippet.ResourceID.VideoID)
 		video.ID = item.Snippet.ResourceID.VideoID
 
-		yearPub, err := strconv.Atoi(video.PublishedAt[0:4])
-		if err != nil {
-			log.Printf("invalid yearPublished %q: %v", video.PublishedAt[0:4], err)
+		video, ok := seasons.Number(video)
+		if !ok {
+			log.Printf("No season found for: %s", video.Title)
 			continue
 		}
 
-		// Splitting between getting ALL videos from a channel VS getting something that have seasons and episodes in the name
-		if strings.Contains(normalizeTitle(video.Title), "episode") && strings.Contains(normalizeTitle(video.Title), "season") {
-			video.Title, video.Season, video.Episode, err = extractEpisodeInfo(video.Title)
-			if err != nil {
-				log.Print("Problem with extracting episode info", err)
-			}
-		} else {
-			season := yearPub - startYear + 1
-			video.Season = fmt.Sprintf("%02d", season)
-
-			log.Printf("Season: %d CurrentSeasson: %d", season, currentSeason)
-			if season == currentSeason {
-				currentEpisode++
-			} else {
-				currentEpisode = 1
-				currentSeason = season
-			}
-
-			video.Episode = fmt.Sprintf("%02d", currentEpisode)
-		}
-
-		video = YT.FilePathAndName(video)
-
 		videosData = append(videosData, video)
 		printData(video)
 	}
@@ -129,7 +87,8 @@ func (YT YouTubeChannel) SetVideoSearchDetails(video models.Video, snippet Snipp
 	video.Description = snippet.Description
 	video.Title = strings.Replace(snippet.Title, "\u0026#39;", "", -1)
 	video.PublishedAt = snippet.PublishedAt
-	video.ThumbnailURL = snippet.Thumbnails.Maxres.URL
+	// Not every video has a maxres thumbnail
+	video.ThumbnailURL = biggestThumbnail(snippet.Thumbnails)
 
 	return video
 }
@@ -4,53 +4,50 @@ import (
 	"download-youtube/models"
 	"encoding/json"
 	"fmt"
-	"io"
+	"html"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func (YT YouTubeChannel) GetSearchResultVideos() ([]SearchResult, error) {
+// GetSearchResultVideos searches the videos of the channel, newest first. Stops at the first page that only has
+// known videos, a nil known pages through everything. A non zero publishedAfter only searches the videos after it
+func (YT YouTubeChannel) GetSearchResultVideos(known map[string]bool, publishedAfter time.Time) ([]SearchResult, error) {
 	nextPageToken := ""
 	totalFetched := 0
 
-	client := &http.Client{}
 	var videoData []SearchResult
 
 	for {
-		url, err := YT.buildURL(nextPageToken)
+		url, err := YT.buildURL("", nextPageToken)
 		if err != nil {
 			log.Printf("Error building URL: %v", err)
 			return videoData, err
 		}
-
-		resp, err := client.Get(url)
-		if err != nil {
-			log.Printf("Error fetching URL %s: %v", url, err)
-			return videoData, err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			err := fmt.Errorf("received status code %d for URL %s: %s", resp.StatusCode, url, body)
-			log.Print(err)
-			return videoData, err
+		if !publishedAfter.IsZero() {
+			url += "&publishedAfter=" + publishedAfter.UTC().Format(time.RFC3339)
 		}
 
 		var res APIResponse
-		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-			log.Printf("Error decoding response from %s: %v", url, err)
+		if err := YT.getJSON(url, &res); err != nil {
+			log.Print(err)
 			return videoData, err
 		}
 
 		videoData = append(videoData, res.Items...)
 		totalFetched++
 
-		if res.NextPageToken == "" || totalFetched >= defaultMaxResults {
+		var ids []string
+		for _, item := range res.Items {
+			ids = append(ids, item.ID.VideoID)
+		}
+		if onlyKnown(known, ids) {
+			log.Print("Page only has known videos, stopping")
+			break
+		}
+
+		if res.NextPageToken == "" || totalFetched >= maxSearchPages {
 			break
 		}
 
@@ -79,16 +76,7 @@ func (YT YouTubeChannel) GetSearchResultVideosDEBUG() []SearchResult {
 }
 
 // extractInformation takes the response JSON and saves it to our Video Struct
-func (YT YouTubeChannel) ExtractSearchResultInfo(videoData []SearchResult) []models.Video {
-	var currentEpisode = 1
-	var currentSeason = 1
-
-	startYear, err := strconv.Atoi(YT.EnvVar.SeasonStartYear)
-	if err != nil {
-		log.Printf("invalid SEASON_START_YEAR %q: %v", YT.EnvVar.SeasonStartYear, err)
-		return nil
-	}
-
+func (YT YouTubeChannel) ExtractSearchResultInfo(videoData []SearchResult, seasons SeasonStrategy) []models.Video {
 	var videosData []models.Video
 
 	for _, item := range videoData {
@@ -104,35 +92,12 @@ func (YT YouTubeChannel) ExtractSearchResultInfo(videoData []SearchResult) []mod
 		video.URL = fmt.Sprintf("https://www.youtube.com/watch?v=%s", item.ID.VideoID)
 		video.ID = item.ID.VideoID
 
-		yearPub, err := strconv.Atoi(video.PublishedAt[0:4])
-		if err != nil {
-			log.Printf("invalid yearPublished %q: %v", video.PublishedAt[0:4], err)
+		video, ok := seasons.Number(video)
+		if !ok {
+			log.Printf("No season found for: %s", video.Title)
 			continue
 		}
 
-		// Splitting between getting ALL videos from a channel VS getting something that have seasons and episodes in the name
-		if strings.Contains(normalizeTitle(video.Title), "episode") && strings.Contains(normalizeTitle(video.Title), "season") {
-			video.Title, video.Episode, video.Season, err = extractEpisodeInfo(video.Title)
-			if err != nil {
-				log.Print("Problem with extracting episode info", err)
-			}
-		} else {
-			season := yearPub - startYear + 1
-			video.Season = fmt.Sprintf("%02d", season)
-
-			log.Printf("Season: %d CurrentSeasson: %d", season, currentSeason)
-			if season == currentSeason {
-				currentEpisode++
-			} else {
-				currentEpisode = 1
-				currentSeason = season
-			}
-
-			video.Episode = fmt.Sprintf("%02d", currentEpisode)
-		}
-
-		video = YT.FilePathAndName(video)
-
 		videosData = append(videosData, video)
 		printData(video)
 	}
@@ -140,10 +105,11 @@ func (YT YouTubeChannel) ExtractSearchResultInfo(videoData []SearchResult) []mod
 	return videosData
 }
 
+// SetVideoPlaylistDetails copies the search snippet, search.list returns the title and description HTML escaped
 func (YT YouTubeChannel) SetVideoPlaylistDetails(video models.Video, snippet SearchSnippet) models.Video {
 	video.ChannelTitle = snippet.ChannelTitle
-	video.Description = snippet.Description
-	video.Title = strings.Replace(snippet.Title, "\u0026#39;", "", -1)
+	video.Description = html.UnescapeString(snippet.Description)
+	video.Title = html.UnescapeString(strings.Replace(snippet.Title, "\u0026#39;", "", -1))
 	video.PublishedAt = snippet.PublishedAt
 	video.ThumbnailURL = getThumbUrl(snippet.Thumbnails)
 
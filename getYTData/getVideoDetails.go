@@ -0,0 +1,124 @@
+package getYTData
+
+import (
+	"download-youtube/models"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	videosEndpoint          = "videos"
+	videoCategoriesEndpoint = "videoCategories"
+)
+
+// EnrichVideos adds the duration, tags, category, statistics and language of the videos using videos.list,
+// 50 videos per call as that is the most the API accepts
+func (YT YouTubeChannel) EnrichVideos(videos []models.Video) ([]models.Video, error) {
+	details := make(map[string]VideoItem)
+
+	for start := 0; start < len(videos); start += defaultMaxResults {
+		end := min(start+defaultMaxResults, len(videos))
+
+		var ids []string
+		for _, video := range videos[start:end] {
+			ids = append(ids, video.ID)
+		}
+
+		url := fmt.Sprintf("%s/%s?key=%s&id=%s&part=snippet,contentDetails,statistics",
+			baseURL, videosEndpoint, YT.EnvVar.ApiKey, strings.Join(ids, ","))
+
+		var res VideoListResponse
+		if err := YT.getJSON(url, &res); err != nil {
+			return videos, err
+		}
+
+		for _, item := range res.Items {
+			details[item.ID] = item
+		}
+	}
+
+	categories, err := YT.videoCategories(details)
+	if err != nil {
+		log.Print("Problem getting the video categories: ", err)
+	}
+
+	fetchedAt := time.Now().UTC().Format(time.RFC3339)
+	for i, video := range videos {
+		videos[i].DetailsFetchedAt = fetchedAt
+
+		item, ok := details[video.ID]
+		if !ok {
+			log.Printf("No details found for: %s", video.Title)
+			continue
+		}
+
+		videos[i].Duration = parseISODuration(item.ContentDetails.Duration)
+		videos[i].Definition = item.ContentDetails.Definition
+		videos[i].Tags = item.Snippet.Tags
+		videos[i].CategoryID = item.Snippet.CategoryID
+		videos[i].Category = categories[item.Snippet.CategoryID]
+		videos[i].AudioLanguage = item.Snippet.DefaultAudioLanguage
+		videos[i].ViewCount = item.Statistics.ViewCount
+		videos[i].LikeCount = item.Statistics.LikeCount
+		videos[i].CommentCount = item.Statistics.CommentCount
+	}
+
+	log.Printf("Added details to %d of %d videos", len(details), len(videos))
+
+	return videos, nil
+}
+
+// videoCategories looks up the names of the categories used by the videos
+func (YT YouTubeChannel) videoCategories(details map[string]VideoItem) (map[string]string, error) {
+	categories := make(map[string]string)
+
+	var ids []string
+	for _, item := range details {
+		if _, exists := categories[item.Snippet.CategoryID]; !exists && item.Snippet.CategoryID != "" {
+			categories[item.Snippet.CategoryID] = ""
+			ids = append(ids, item.Snippet.CategoryID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return categories, nil
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s&id=%s&part=snippet",
+		baseURL, videoCategoriesEndpoint, YT.EnvVar.ApiKey, strings.Join(ids, ","))
+
+	var res VideoCategoryListResponse
+	if err := YT.getJSON(url, &res); err != nil {
+		return categories, err
+	}
+
+	for _, item := range res.Items {
+		categories[item.ID] = item.Snippet.Title
+	}
+
+	return categories, nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseISODuration turns the ISO 8601 duration YouTube uses, e.g. PT1H2M3S, into seconds
+func parseISODuration(duration string) int {
+	matches := isoDurationPattern.FindStringSubmatch(duration)
+	if matches == nil {
+		if duration != "" {
+			log.Printf("invalid duration %q", duration)
+		}
+		return 0
+	}
+
+	seconds := 0
+	for i, unit := range []int{24 * 60 * 60, 60 * 60, 60, 1} {
+		value, _ := strconv.Atoi(matches[i+1])
+		seconds += value * unit
+	}
+	return seconds
+}
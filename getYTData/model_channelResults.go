@@ -0,0 +1,63 @@
+package getYTData
+
+// ChannelListResponse represents the top-level response from the channels endpoint.
+type ChannelListResponse struct {
+	Kind     string        `json:"kind"`
+	Etag     string        `json:"etag"`
+	PageInfo PageInfo      `json:"pageInfo"`
+	Items    []ChannelItem `json:"items"`
+}
+
+// ChannelItem represents a single channel.
+type ChannelItem struct {
+	Kind           string                `json:"kind"`
+	Etag           string                `json:"etag"`
+	ID             string                `json:"id"`
+	Snippet        ChannelSnippet        `json:"snippet"`
+	ContentDetails ChannelContentDetails `json:"contentDetails"`
+}
+
+// ChannelSnippet contains the channel title, description and avatar.
+type ChannelSnippet struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	CustomURL   string     `json:"customUrl"`
+	PublishedAt string     `json:"publishedAt"`
+	Thumbnails  Thumbnails `json:"thumbnails"`
+	Country     string     `json:"country"`
+}
+
+// ChannelContentDetails contains the playlists YouTube keeps for the channel.
+type ChannelContentDetails struct {
+	RelatedPlaylists struct {
+		Likes   string `json:"likes"`
+		Uploads string `json:"uploads"`
+	} `json:"relatedPlaylists"`
+}
+
+// PlaylistListResponse represents the top-level response from the playlists endpoint.
+type PlaylistListResponse struct {
+	Kind          string         `json:"kind"`
+	Etag          string         `json:"etag"`
+	NextPageToken string         `json:"nextPageToken,omitempty"`
+	PageInfo      PageInfo       `json:"pageInfo"`
+	Items         []PlaylistInfo `json:"items"`
+}
+
+// PlaylistInfo represents a single playlist.
+type PlaylistInfo struct {
+	Kind    string          `json:"kind"`
+	Etag    string          `json:"etag"`
+	ID      string          `json:"id"`
+	Snippet PlaylistSnippet `json:"snippet"`
+}
+
+// PlaylistSnippet contains details about the playlist and the channel owning it.
+type PlaylistSnippet struct {
+	PublishedAt  string     `json:"publishedAt"`
+	ChannelID    string     `json:"channelId"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Thumbnails   Thumbnails `json:"thumbnails"`
+	ChannelTitle string     `json:"channelTitle"`
+}
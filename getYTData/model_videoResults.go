@@ -0,0 +1,51 @@
+package getYTData
+
+// VideoListResponse represents the top-level response from the videos endpoint.
+type VideoListResponse struct {
+	Kind     string      `json:"kind"`
+	Etag     string      `json:"etag"`
+	PageInfo PageInfo    `json:"pageInfo"`
+	Items    []VideoItem `json:"items"`
+}
+
+// VideoItem represents a single video with the details search and playlistItems don't have.
+type VideoItem struct {
+	Kind           string              `json:"kind"`
+	Etag           string              `json:"etag"`
+	ID             string              `json:"id"`
+	Snippet        VideoSnippet        `json:"snippet"`
+	ContentDetails VideoContentDetails `json:"contentDetails"`
+	Statistics     VideoStatistics     `json:"statistics"`
+}
+
+// VideoSnippet contains the tags, category and language of the video.
+type VideoSnippet struct {
+	Tags                 []string `json:"tags"`
+	CategoryID           string   `json:"categoryId"`
+	DefaultAudioLanguage string   `json:"defaultAudioLanguage"`
+}
+
+// VideoContentDetails contains the duration (ISO 8601) and if the video is hd or sd.
+type VideoContentDetails struct {
+	Duration   string `json:"duration"`
+	Definition string `json:"definition"`
+}
+
+// VideoStatistics contains the counts, YouTube sends them as strings.
+type VideoStatistics struct {
+	ViewCount    int64 `json:"viewCount,string"`
+	LikeCount    int64 `json:"likeCount,string"`
+	CommentCount int64 `json:"commentCount,string"`
+}
+
+// VideoCategoryListResponse represents the top-level response from the videoCategories endpoint.
+type VideoCategoryListResponse struct {
+	Kind  string `json:"kind"`
+	Etag  string `json:"etag"`
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title string `json:"title"`
+		} `json:"snippet"`
+	} `json:"items"`
+}
@@ -0,0 +1,95 @@
+package getYTData
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"download-youtube/models"
+)
+
+// NumberEpisodes gives the added videos that are not numbered from their title the next episode numbers of their
+// season, oldest first. The numbers of the existing videos are never changed, so new uploads are always appended
+func NumberEpisodes(existing, added []models.Video) {
+	lastEpisode := make(map[string]int)
+	for _, video := range existing {
+		episode, _ := strconv.Atoi(video.Episode)
+		lastEpisode[video.Season] = max(lastEpisode[video.Season], episode)
+	}
+	for _, video := range added {
+		if video.Episode == "" {
+			continue
+		}
+		episode, _ := strconv.Atoi(video.Episode)
+		lastEpisode[video.Season] = max(lastEpisode[video.Season], episode)
+	}
+
+	var indexes []int
+	for i, video := range added {
+		if video.Episode == "" {
+			indexes = append(indexes, i)
+		}
+	}
+	sortByPublished(added, indexes)
+
+	for _, i := range indexes {
+		lastEpisode[added[i].Season]++
+		added[i].Episode = fmt.Sprintf("%02d", lastEpisode[added[i].Season])
+	}
+}
+
+// RenumberEpisodes numbers the episodes of every season again by publish date, oldest first, in place.
+// Videos numbered from their title keep their number and it is skipped. Returns the indexes of the videos that
+// got a new number
+func RenumberEpisodes(videos []models.Video) []int {
+	seasons := make(map[string][]int)
+	taken := make(map[string]map[int]bool)
+	for i, video := range videos {
+		if video.NumberedFromTitle {
+			if taken[video.Season] == nil {
+				taken[video.Season] = make(map[int]bool)
+			}
+			episode, _ := strconv.Atoi(video.Episode)
+			taken[video.Season][episode] = true
+			continue
+		}
+		seasons[video.Season] = append(seasons[video.Season], i)
+	}
+
+	var changed []int
+	for season, indexes := range seasons {
+		sortByPublished(videos, indexes)
+
+		episode := 0
+		for _, i := range indexes {
+			episode++
+			for taken[season][episode] {
+				episode++
+			}
+
+			number := fmt.Sprintf("%02d", episode)
+			if videos[i].Episode == number {
+				continue
+			}
+
+			log.Printf("Renumbering %s: S%sE%s -> S%sE%s", videos[i].Title, season, videos[i].Episode, season, number)
+			videos[i].Episode = number
+			changed = append(changed, i)
+		}
+	}
+
+	sort.Ints(changed)
+	return changed
+}
+
+// sortByPublished sorts the indexes by the publish date of the videos, the video ID decides between the same date
+func sortByPublished(videos []models.Video, indexes []int) {
+	sort.SliceStable(indexes, func(a, b int) bool {
+		videoA, videoB := videos[indexes[a]], videos[indexes[b]]
+		if videoA.PublishedAt != videoB.PublishedAt {
+			return videoA.PublishedAt < videoB.PublishedAt
+		}
+		return videoA.ID < videoB.ID
+	})
+}
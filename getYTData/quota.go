@@ -0,0 +1,218 @@
+package getYTData
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // The quota day is in Pacific time, also where the OS has no time zones
+
+	"download-youtube/models"
+)
+
+// DefaultQuotaBudget is the daily quota YouTube gives a project
+const DefaultQuotaBudget = 10000
+
+// quotaCosts are the units a call to the endpoint costs, anything not listed costs 1
+var quotaCosts = map[string]int{
+	searchEndpoint: 100,
+}
+
+// quotaTimezone is where the quota resets at midnight
+var quotaTimezone = mustLoadLocation("America/Los_Angeles")
+
+// DefaultQuotaPath is where the usage of the API key is saved when no file is set. It is in the user cache folder,
+// so every source and save location using the key shares it, and named after a hash of the key instead of the key
+func DefaultQuotaPath(apiKey string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	hash := sha256.Sum256([]byte(apiKey))
+	return filepath.Join(dir, "download-youtube", "api-quota-"+hex.EncodeToString(hash[:6])+".json")
+}
+
+// QuotaTracker counts the quota units used by the calls to the YouTube Data API and keeps the usage of the day in
+// a file, so every run and source using it shares the same budget. A nil tracker does not count anything
+type QuotaTracker struct {
+	Path string
+	// Budget is the most units used in a day, 0 is no limit
+	Budget int
+
+	mu    sync.Mutex
+	usage quotaUsage
+	run   int
+}
+
+// quotaLockTimeout is how long the lock file of the usage is kept at most, an older one was left by a run that
+// stopped and is removed
+const quotaLockTimeout = 10 * time.Second
+
+// quotaUsage is what is saved in the file
+type quotaUsage struct {
+	// Day is the Pacific date the usage is for
+	Day       string         `json:"day"`
+	Units     int            `json:"units"`
+	Endpoints map[string]int `json:"endpoints"`
+	// Exhausted is set when YouTube answered quotaExceeded, no more calls are made that day
+	Exhausted bool `json:"exhausted"`
+}
+
+// Spend counts a call to the endpoint, it is refused when it would go over the budget
+func (q *QuotaTracker) Spend(endpoint string) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.lock()()
+	q.load()
+
+	cost := quotaCost(endpoint)
+	if q.usage.Exhausted {
+		return fmt.Errorf("%w: YouTube refused calls earlier today, not calling %s until the quota resets at midnight Pacific time",
+			models.ErrQuotaExceeded, endpoint)
+	}
+	if q.Budget > 0 && q.usage.Units+cost > q.Budget {
+		return fmt.Errorf("%w: %s costs %d units and %d of the budget of %d are used today",
+			models.ErrQuotaExceeded, endpoint, cost, q.usage.Units, q.Budget)
+	}
+
+	q.usage.Units += cost
+	q.usage.Endpoints[endpoint] += cost
+	q.run += cost
+	q.save()
+
+	return nil
+}
+
+// Exhausted marks the quota of the day as used up
+func (q *QuotaTracker) Exhausted() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer q.lock()()
+	q.load()
+
+	q.usage.Exhausted = true
+	q.save()
+}
+
+// Summary is the usage of the run and the day, e.g. for the end of a run
+func (q *QuotaTracker) Summary() string {
+	if q == nil {
+		return "YouTube API quota is not tracked"
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.load()
+
+	var endpoints []string
+	for endpoint, units := range q.usage.Endpoints {
+		endpoints = append(endpoints, fmt.Sprintf("%s %d", endpoint, units))
+	}
+	sort.Strings(endpoints)
+
+	budget := "no budget"
+	if q.Budget > 0 {
+		budget = fmt.Sprintf("budget %d", q.Budget)
+	}
+	summary := fmt.Sprintf("YouTube API quota: %d units used by this run, %d today (%s)", q.run, q.usage.Units, budget)
+	if len(endpoints) > 0 {
+		summary += ": " + strings.Join(endpoints, ", ")
+	}
+	if q.usage.Exhausted {
+		summary += ", exhausted until midnight Pacific time"
+	}
+	return summary
+}
+
+// load reads the usage again, other runs can have used the key since, and starts over when the day has changed.
+// The caller holds the lock
+func (q *QuotaTracker) load() {
+	q.usage = quotaUsage{}
+	if usageByte, err := os.ReadFile(q.Path); err == nil {
+		if err := json.Unmarshal(usageByte, &q.usage); err != nil {
+			log.Printf("Problem reading the quota usage in %s, starting over: %v", q.Path, err)
+			q.usage = quotaUsage{}
+		}
+	}
+
+	if today := quotaDay(time.Now()); q.usage.Day != today {
+		q.usage = quotaUsage{Day: today}
+	}
+	if q.usage.Endpoints == nil {
+		q.usage.Endpoints = make(map[string]int)
+	}
+}
+
+// lock keeps other runs from changing the usage between reading and saving it, returns the unlock. A usage that
+// can not be locked is still counted
+func (q *QuotaTracker) lock() func() {
+	lockPath := q.Path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		log.Printf("Problem locking the quota usage in %s: %v", q.Path, err)
+		return func() {}
+	}
+
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			lockFile.Close()
+			return func() { os.Remove(lockPath) }
+		}
+		if !errors.Is(err, os.ErrExist) {
+			log.Printf("Problem locking the quota usage in %s: %v", q.Path, err)
+			return func() {}
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > quotaLockTimeout {
+			log.Printf("Removing the lock of the quota usage left by a stopped run: %s", lockPath)
+			os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// save writes the usage, a usage that can not be saved is only logged, the caller holds the lock
+func (q *QuotaTracker) save() {
+	usageByte, err := json.MarshalIndent(q.usage, "", "  ")
+	if err == nil {
+		err = models.WriteState(q.Path, usageByte, 0)
+	}
+	if err != nil {
+		log.Printf("Problem saving the quota usage to %s: %v", q.Path, err)
+	}
+}
+
+// quotaDay is the Pacific date of the time, the quota resets at midnight Pacific time
+func quotaDay(now time.Time) string {
+	return now.In(quotaTimezone).Format(time.DateOnly)
+}
+
+func quotaCost(endpoint string) int {
+	if cost, found := quotaCosts[endpoint]; found {
+		return cost
+	}
+	return 1
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
@@ -0,0 +1,144 @@
+package getYTData
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"download-youtube/models"
+)
+
+func readUsage(t *testing.T, path string) quotaUsage {
+	t.Helper()
+	var usage quotaUsage
+	usageByte, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(usageByte, &usage); err != nil {
+		t.Fatal(err)
+	}
+	return usage
+}
+
+func TestQuotaRefusesOverBudget(t *testing.T) {
+	quota := &QuotaTracker{Path: filepath.Join(t.TempDir(), "quota.json"), Budget: 250}
+
+	for i, endpoint := range []string{searchEndpoint, searchEndpoint, videosEndpoint} {
+		if err := quota.Spend(endpoint); err != nil {
+			t.Fatalf("call %d to %s: %v", i, endpoint, err)
+		}
+	}
+	if err := quota.Spend(searchEndpoint); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("search over the budget: %v, want %v", err, models.ErrQuotaExceeded)
+	}
+	// What is left can still be used by the cheaper calls
+	if err := quota.Spend(videosEndpoint); err != nil {
+		t.Fatalf("videos under the budget: %v", err)
+	}
+
+	usage := readUsage(t, quota.Path)
+	if usage.Units != 202 || usage.Endpoints[searchEndpoint] != 200 || usage.Endpoints[videosEndpoint] != 2 {
+		t.Errorf("usage = %+v, want 202 units", usage)
+	}
+}
+
+func TestQuotaExhausted(t *testing.T) {
+	quota := &QuotaTracker{Path: filepath.Join(t.TempDir(), "quota.json")}
+
+	quota.Exhausted()
+	if err := quota.Spend(videosEndpoint); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("call after quotaExceeded: %v, want %v", err, models.ErrQuotaExceeded)
+	}
+
+	// Another run with the same file does not call either
+	other := &QuotaTracker{Path: quota.Path}
+	if err := other.Spend(videosEndpoint); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Fatalf("call of another run after quotaExceeded: %v, want %v", err, models.ErrQuotaExceeded)
+	}
+}
+
+func TestQuotaDayIsPacific(t *testing.T) {
+	tests := []struct {
+		utc  string
+		want string
+	}{
+		// PST is UTC-8
+		{"2024-01-15T07:59:59Z", "2024-01-14"},
+		{"2024-01-15T08:00:00Z", "2024-01-15"},
+		// PDT is UTC-7
+		{"2024-07-15T06:59:59Z", "2024-07-14"},
+		{"2024-07-15T07:00:00Z", "2024-07-15"},
+	}
+
+	for _, test := range tests {
+		now, err := time.Parse(time.RFC3339, test.utc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := quotaDay(now); got != test.want {
+			t.Errorf("quotaDay(%s) = %s, want %s", test.utc, got, test.want)
+		}
+	}
+}
+
+func TestQuotaResetsOnANewDay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	yesterday := quotaUsage{Day: quotaDay(time.Now().Add(-24 * time.Hour)), Units: 9950, Exhausted: true,
+		Endpoints: map[string]int{searchEndpoint: 9950}}
+	usageByte, err := json.Marshal(yesterday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, usageByte, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	quota := &QuotaTracker{Path: path, Budget: DefaultQuotaBudget}
+	if err := quota.Spend(searchEndpoint); err != nil {
+		t.Fatalf("first call of the day: %v", err)
+	}
+
+	usage := readUsage(t, path)
+	if usage.Day != quotaDay(time.Now()) || usage.Units != 100 || usage.Exhausted {
+		t.Errorf("usage = %+v, want 100 units today", usage)
+	}
+}
+
+// TestQuotaSharedBetweenRuns has two trackers on the same file, as two runs at the same time would
+func TestQuotaSharedBetweenRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	runs := []*QuotaTracker{{Path: path}, {Path: path}}
+
+	const calls = 25
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				if err := run.Spend(videosEndpoint); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if usage := readUsage(t, path); usage.Units != 2*calls {
+		t.Errorf("%d units saved, want %d", usage.Units, 2*calls)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+
+	// The budget counts the calls of the other run
+	limited := &QuotaTracker{Path: path, Budget: 2 * calls}
+	if err := limited.Spend(videosEndpoint); !errors.Is(err, models.ErrQuotaExceeded) {
+		t.Errorf("call over the shared budget: %v, want %v", err, models.ErrQuotaExceeded)
+	}
+}
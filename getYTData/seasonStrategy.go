@@ -0,0 +1,315 @@
+package getYTData
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"download-youtube/models"
+)
+
+// specialsSeason is where Kodi expects the videos that are not part of a season
+const specialsSeason = 0
+
+// defaultTitlePattern is the "Title | ... EPISODE <number> | Season <number>" format used when NUMBERING_MODE is auto
+var defaultTitlePattern = regexp.MustCompile(`^(?P<title>.*?)\s*\|\s*.*(?i:episode)\s+(?P<episode>\d+)\s*\|\s*(?i:season)\s+(?P<season>\d+)`)
+
+// SeasonStrategy decides the season of a video. A strategy that knows the episode as well sets it, otherwise the
+// episode is left empty for NumberEpisodes. Returns false when the strategy can not place the video
+type SeasonStrategy interface {
+	Number(video models.Video) (models.Video, bool)
+}
+
+// seasonStrategies tries every strategy in order until one can place the video
+type seasonStrategies []SeasonStrategy
+
+func (strategies seasonStrategies) Number(video models.Video) (models.Video, bool) {
+	for _, strategy := range strategies {
+		if numbered, ok := strategy.Number(video); ok {
+			return numbered, true
+		}
+	}
+	return video, false
+}
+
+// yearSeasons uses the year the video was published, the season start year is season 1
+type yearSeasons struct {
+	startYear int
+}
+
+func (s yearSeasons) Number(video models.Video) (models.Video, bool) {
+	if len(video.PublishedAt) < 4 {
+		return video, false
+	}
+	yearPub, err := strconv.Atoi(video.PublishedAt[0:4])
+	if err != nil {
+		log.Printf("invalid yearPublished %q: %v", video.PublishedAt[0:4], err)
+		return video, false
+	}
+
+	video.Season = fmt.Sprintf("%02d", yearPub-s.startYear+1)
+	return video, true
+}
+
+// fixedSeason puts every video in the same season
+type fixedSeason struct {
+	season int
+}
+
+func (s fixedSeason) Number(video models.Video) (models.Video, bool) {
+	video.Season = fmt.Sprintf("%02d", s.season)
+	return video, true
+}
+
+// dateSeasons starts a new season at every date, videos before the first one are specials
+type dateSeasons struct {
+	starts []time.Time
+}
+
+func (s dateSeasons) Number(video models.Video) (models.Video, bool) {
+	published, err := time.Parse(time.RFC3339, video.PublishedAt)
+	if err != nil {
+		log.Printf("invalid publishedAt %q: %v", video.PublishedAt, err)
+		return video, false
+	}
+
+	season := sort.Search(len(s.starts), func(i int) bool {
+		return s.starts[i].After(published)
+	})
+	video.Season = fmt.Sprintf("%02d", season)
+	return video, true
+}
+
+// playlistWait is how long a new video that is in no playlist is left unsaved, for the creator to add it to one
+const playlistWait = 7 * 24 * time.Hour
+
+// lateSpecials puts the videos published longer than wait ago in the specials season. Seasons are never changed
+// once saved, so a newer video is left for a later sync instead
+type lateSpecials struct {
+	wait time.Duration
+}
+
+func (s lateSpecials) Number(video models.Video) (models.Video, bool) {
+	if published, err := time.Parse(time.RFC3339, video.PublishedAt); err == nil && time.Since(published) < s.wait {
+		log.Printf("%s is in no playlist yet, leaving it for a later sync", video.Title)
+		return video, false
+	}
+
+	video.Season = fmt.Sprintf("%02d", specialsSeason)
+	return video, true
+}
+
+// playlistSeasons has a season for every playlist of the channel, a video in more than one playlist is in the
+// first season
+type playlistSeasons struct {
+	seasons map[string]int
+}
+
+func (s playlistSeasons) Number(video models.Video) (models.Video, bool) {
+	season, found := s.seasons[video.ID]
+	if !found {
+		return video, false
+	}
+	video.Season = fmt.Sprintf("%02d", season)
+	return video, true
+}
+
+// titleSeasons reads the season, episode and title from the title, for the groups the pattern has. When the title
+// has no season it comes from seasons
+type titleSeasons struct {
+	pattern *regexp.Regexp
+	cleanup []titleCleanup
+	seasons SeasonStrategy
+}
+
+// titleCleanup is a replacement made to the title after the pattern matched
+type titleCleanup struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+func (s titleSeasons) Number(video models.Video) (models.Video, bool) {
+	matches := s.pattern.FindStringSubmatch(normalizeYouTubeTitle(video.Title))
+	if matches == nil {
+		return video, false
+	}
+
+	groups := make(map[string]string)
+	for i, name := range s.pattern.SubexpNames() {
+		if name != "" && matches[i] != "" {
+			groups[name] = matches[i]
+		}
+	}
+	if groups["season"] == "" && groups["episode"] == "" {
+		return video, false
+	}
+
+	numbered := video
+	if title := strings.TrimSpace(groups["title"]); title != "" {
+		numbered.Title = title
+	}
+	for _, cleanup := range s.cleanup {
+		numbered.Title = strings.TrimSpace(cleanup.pattern.ReplaceAllString(numbered.Title, cleanup.replace))
+	}
+
+	if episode, err := strconv.Atoi(groups["episode"]); err == nil {
+		numbered.Episode = fmt.Sprintf("%02d", episode)
+		numbered.NumberedFromTitle = true
+	}
+
+	if season, err := strconv.Atoi(groups["season"]); err == nil {
+		numbered.Season = fmt.Sprintf("%02d", season)
+		return numbered, true
+	}
+	if s.seasons == nil {
+		return video, false
+	}
+	return s.seasons.Number(numbered)
+}
+
+// seasonStrategy builds the strategies of the source, for the unsaved videos. When NUMBERING_MODE is auto the title
+// rules, and then the built-in pattern, are tried first
+func (YT YouTubeChannel) seasonStrategy(unsaved map[string]bool) (SeasonStrategy, error) {
+	var strategies seasonStrategies
+
+	switch YT.EnvVar.SeasonStrategy {
+	case models.SeasonsFixed:
+		strategies = append(strategies, fixedSeason{season: 1})
+	case models.SeasonsByDates:
+		starts, err := YT.EnvVar.SeasonStarts()
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, dateSeasons{starts: starts})
+	case models.SeasonsByPlaylist:
+		byPlaylist, err := YT.playlistSeasons(unsaved)
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, byPlaylist, lateSpecials{wait: playlistWait})
+	case models.SeasonsByTitle:
+		pattern, err := YT.EnvVar.TitleRegexp()
+		if err != nil {
+			return nil, err
+		}
+		byYear, err := YT.yearSeasons()
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, titleSeasons{pattern: pattern}, byYear)
+	default:
+		byYear, err := YT.yearSeasons()
+		if err != nil {
+			return nil, err
+		}
+		strategies = append(strategies, byYear)
+	}
+
+	if YT.EnvVar.Numbering == models.NumberingDate {
+		return strategies, nil
+	}
+
+	fromTitle, err := YT.titleStrategies(strategies)
+	if err != nil {
+		return nil, err
+	}
+	return append(fromTitle, strategies...), nil
+}
+
+func (YT YouTubeChannel) yearSeasons() (yearSeasons, error) {
+	startYear, err := strconv.Atoi(YT.EnvVar.SeasonStartYear)
+	if err != nil {
+		return yearSeasons{}, fmt.Errorf("invalid SEASON_START_YEAR %q: %v", YT.EnvVar.SeasonStartYear, err)
+	}
+	return yearSeasons{startYear: startYear}, nil
+}
+
+// titleStrategies are the title rules in order followed by the built-in pattern, which is left out for the regex
+// season strategy. A title without a season gets it from seasons
+func (YT YouTubeChannel) titleStrategies(seasons SeasonStrategy) (seasonStrategies, error) {
+	var strategies seasonStrategies
+
+	rules, err := YT.EnvVar.ParseTitleRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		strategy := titleSeasons{pattern: regexp.MustCompile(rule.Pattern), seasons: seasons}
+		for _, cleanup := range rule.Cleanup {
+			strategy.cleanup = append(strategy.cleanup, titleCleanup{pattern: regexp.MustCompile(cleanup.Pattern), replace: cleanup.Replace})
+		}
+		strategies = append(strategies, strategy)
+	}
+
+	if YT.EnvVar.SeasonStrategy != models.SeasonsByTitle {
+		strategies = append(strategies, titleSeasons{pattern: defaultTitlePattern})
+	}
+
+	return strategies, nil
+}
+
+// playlistSeasons lists the playlists of the channel, oldest first, and which of the unsaved videos are in them.
+// Stops once every one of them has a season, without unsaved videos the playlists are not listed at all
+func (YT YouTubeChannel) playlistSeasons(unsaved map[string]bool) (playlistSeasons, error) {
+	seasons := playlistSeasons{seasons: make(map[string]int)}
+	if len(unsaved) == 0 {
+		return seasons, nil
+	}
+
+	channelID := YT.EnvVar.ChannelID
+	if channelID == "" {
+		var err error
+		channelID, err = YT.playlistChannelID()
+		if err != nil {
+			return seasons, err
+		}
+	}
+
+	var playlists []PlaylistInfo
+	pageToken := ""
+	for {
+		url := fmt.Sprintf("%s/%s?key=%s&channelId=%s&part=snippet&maxResults=%d&pageToken=%s",
+			baseURL, playlistsEndpoint, YT.EnvVar.ApiKey, channelID, defaultMaxResults, pageToken)
+
+		var res PlaylistListResponse
+		if err := YT.getJSON(url, &res); err != nil {
+			return seasons, err
+		}
+		playlists = append(playlists, res.Items...)
+
+		if res.NextPageToken == "" {
+			break
+		}
+		pageToken = res.NextPageToken
+	}
+
+	sort.SliceStable(playlists, func(i, j int) bool {
+		return playlists[i].Snippet.PublishedAt < playlists[j].Snippet.PublishedAt
+	})
+
+	for i, playlist := range playlists {
+		items, err := YT.GetPlaylistSearchResultVideos(playlist.ID, nil)
+		if err != nil {
+			return seasons, err
+		}
+
+		log.Printf("Season %02d: %s (%d videos)", i+1, playlist.Snippet.Title, len(items))
+		for _, item := range items {
+			videoID := item.Snippet.ResourceID.VideoID
+			if _, found := seasons.seasons[videoID]; !found && unsaved[videoID] {
+				seasons.seasons[videoID] = i + 1
+			}
+		}
+
+		// The later playlists can not change the season of a video found already
+		if len(seasons.seasons) == len(unsaved) {
+			break
+		}
+	}
+
+	return seasons, nil
+}
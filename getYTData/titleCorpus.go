@@ -0,0 +1,73 @@
+package getYTData
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"download-youtube/models"
+)
+
+// TitleCase is a title and what the title rules should read from it. An empty season or episode means the title
+// should not give one, an empty want title means the title stays the same
+type TitleCase struct {
+	Title     string `json:"title"`
+	WantTitle string `json:"wantTitle,omitempty"`
+	Season    string `json:"season,omitempty"`
+	Episode   string `json:"episode,omitempty"`
+}
+
+// titleOnly leaves the season empty, so only what is read from the title is checked
+type titleOnly struct{}
+
+func (titleOnly) Number(video models.Video) (models.Video, bool) {
+	return video, true
+}
+
+// CheckTitles runs the title rules against every title in the corpus file and reports the ones that don't give
+// what was expected
+func (YT YouTubeChannel) CheckTitles(corpusPath string) error {
+	corpusByte, err := os.ReadFile(corpusPath)
+	if err != nil {
+		return err
+	}
+
+	var cases []TitleCase
+	if err := json.Unmarshal(corpusByte, &cases); err != nil {
+		return fmt.Errorf("error reading %s: %v", corpusPath, err)
+	}
+
+	rules, err := YT.titleStrategies(titleOnly{})
+	if err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidConfig, err)
+	}
+
+	failed := 0
+	for _, titleCase := range cases {
+		video := models.Video{Title: titleCase.Title}
+		got, ok := rules.Number(video)
+		if !ok {
+			got = video
+		}
+
+		want := titleCase.WantTitle
+		if want == "" {
+			want = titleCase.Title
+		}
+
+		if got.Title != want || got.Season != titleCase.Season || got.Episode != titleCase.Episode {
+			failed++
+			log.Printf("Title did not match: %q", titleCase.Title)
+			log.Printf("  got:  season %q episode %q title %q", got.Season, got.Episode, got.Title)
+			log.Printf("  want: season %q episode %q title %q", titleCase.Season, titleCase.Episode, want)
+		}
+	}
+
+	log.Printf("%d of %d titles matched", len(cases)-failed, len(cases))
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d titles in %s did not give what was expected", failed, len(cases), corpusPath)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package getYTData
+
+import (
+	"path/filepath"
+	"testing"
+
+	"download-youtube/models"
+)
+
+// TestTitleCorpus runs the example title rules against the titles in TestData, the same check as the titles command
+func TestTitleCorpus(t *testing.T) {
+	YT := YouTubeChannel{EnvVar: models.EnvVar{TitleRules: filepath.Join("..", "TestData", "title-rules.json")}}
+
+	if err := YT.CheckTitles(filepath.Join("..", "TestData", "title-corpus.json")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"download-youtube/getYTData"
 	"download-youtube/models"
+	"download-youtube/stateStore"
 
 	"github.com/joho/godotenv"
 )
@@ -18,7 +23,7 @@ type App struct {
 
 func main() {
 	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+		log.Print("No .env file found, using the environment and flags")
 	}
 
 	envVar := models.EnvVar{
@@ -28,30 +33,173 @@ func main() {
 		ChannelName:     os.Getenv("YT_CHANNEL_NAME"),
 		SeasonStartYear: os.Getenv("SEASON_START_YEAR"),
 		SaveLoc:         os.Getenv("SAVE_LOCATION"),
+		Workers:         os.Getenv("DOWNLOAD_WORKERS"),
+		RateLimit:       os.Getenv("DOWNLOAD_RATE_LIMIT"),
+		MaxResolution:   os.Getenv("MAX_RESOLUTION"),
+		VideoCodecs:     os.Getenv("VIDEO_CODECS"),
+		Containers:      os.Getenv("CONTAINERS"),
+		AudioBitrate:    os.Getenv("AUDIO_BITRATE"),
+		AllowMuxed:      os.Getenv("ALLOW_MUXED"),
+		Numbering:       os.Getenv("NUMBERING_MODE"),
+		FetchMode:       os.Getenv("FETCH_MODE"),
+		TemplateDir:     os.Getenv("TEMPLATE_DIR"),
+		FilenameProfile: os.Getenv("FILENAME_PROFILE"),
+		SeasonStrategy:  os.Getenv("SEASON_STRATEGY"),
+		SeasonDates:     os.Getenv("SEASON_DATES"),
+		TitlePattern:    os.Getenv("TITLE_PATTERN"),
+		TitleRules:      os.Getenv("TITLE_RULES"),
+		StateStore:      os.Getenv("STATE_STORE"),
+		CacheTTL:        os.Getenv("API_CACHE_TTL"),
+		QuotaBudget:     os.Getenv("API_QUOTA_BUDGET"),
+		QuotaFile:       os.Getenv("API_QUOTA_FILE"),
 	}
 
-	if err := envVar.Validate(); err != nil {
-		log.Fatal(err)
+	cmd, opts, err := parseCommand(os.Args[1:], &envVar)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitConfig)
+	}
+
+	if opts.Config != "" {
+		exit(runSources(cmd, opts, envVar))
+	}
+
+	validate := envVar.Validate
+	if cmd.Local {
+		validate = envVar.ValidateLocal
+	}
+	if err := validate(); err != nil {
+		log.Print(err)
+		os.Exit(exitConfig)
+	}
+
+	app, err := newApp(envVar)
+	if err != nil {
+		exit(err)
+	}
+
+	err = cmd.Run(app, opts)
+	app.Close(cmd)
+	exit(err)
+}
+
+// runSources runs the command for every source in the config file, the .env values and flags are the defaults for all of them.
+// An invalid or failing source does not stop the other ones
+func runSources(cmd command, opts options, base models.EnvVar) error {
+	config, err := models.LoadConfig(opts.Config)
+	if err != nil {
+		return err
+	}
+
+	envVars, validateErr := config.SourceEnvVars(base, cmd.Local)
+
+	errs := []error{validateErr}
+	for _, envVar := range envVars {
+		log.Printf("Processing source: %s", envVar.ChannelName)
+
+		app, err := newApp(envVar)
+		if err == nil {
+			err = cmd.Run(app, opts)
+			app.Close(cmd)
+		}
+		if err != nil {
+			log.Printf("%s failed: %v", envVar.ChannelName, err)
+			errs = append(errs, fmt.Errorf("%s: %w", envVar.ChannelName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// newApp sets up the app from the validated env values
+func newApp(envVar models.EnvVar) (*App, error) {
+	workers := 1
+	if envVar.Workers != "" {
+		workers, _ = strconv.Atoi(envVar.Workers)
 	}
 
-	jsonFilePath := fmt.Sprintf("%s%s-channel-data.json", envVar.SaveLoc, envVar.ChannelName)
+	rateLimit := 500 * time.Millisecond
+	if envVar.RateLimit != "" {
+		rateLimit, _ = time.ParseDuration(envVar.RateLimit)
+	}
+
+	cacheTTL := time.Hour
+	if envVar.CacheTTL != "" {
+		cacheTTL, _ = time.ParseDuration(envVar.CacheTTL)
+	}
+
+	quotaBudget := getYTData.DefaultQuotaBudget
+	if envVar.QuotaBudget != "" {
+		quotaBudget, _ = strconv.Atoi(envVar.QuotaBudget)
+	}
+	quotaPath := envVar.QuotaFile
+	if quotaPath == "" {
+		quotaPath = getYTData.DefaultQuotaPath(envVar.ApiKey)
+	}
+
+	policy, _ := envVar.FormatPolicy()
+
+	templates, err := loadTemplates(envVar.TemplateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := stateStore.Open(envVar)
+	if err != nil {
+		return nil, err
+	}
+
+	channelPath := fmt.Sprintf("%s%s-channel-info.json", envVar.SaveLoc, envVar.ChannelName)
 
 	var video []models.Video
 
-	app := &App{
+	return &App{
 		Download: Download{
-			JsonFilePath: jsonFilePath,
-			ShowName:     envVar.ChannelName,
-			SaveLoc:      envVar.SaveLoc,
+			Store:       store,
+			ShowName:    envVar.ChannelName,
+			SaveLoc:     envVar.SaveLoc,
+			Workers:     workers,
+			RateLimit:   rateLimit,
+			Policy:      policy,
+			ChannelPath: channelPath,
+			Templates:   templates,
 		},
 		YT: getYTData.YouTubeChannel{
 			EnvVar:              envVar,
-			JsonFilePath:        jsonFilePath,
+			Store:               store,
+			Cache:               &getYTData.APICache{Dir: envVar.SaveLoc + ".api-cache", TTL: cacheTTL},
+			Quota:               &getYTData.QuotaTracker{Path: quotaPath, Budget: quotaBudget},
 			CurrentVideoData:    video,
 			DownloadedVideoData: video,
 		},
+	}, nil
+}
+
+// Close closes the state store and logs the API quota used by commands that talk to YouTube
+func (app *App) Close(cmd command) {
+	if !cmd.Local {
+		log.Print(app.YT.Quota.Summary())
+	}
+	if err := app.Download.Store.Close(); err != nil {
+		log.Print("Problem closing the state store: ", err)
+	}
+}
+
+// Sync refreshes the video data and the tvshow.nfo from YouTube
+func (app *App) Sync() error {
+	if err := app.YT.GetData(); err != nil {
+		return err
+	}
+
+	channel, err := app.YT.GetChannelInfo()
+	if err != nil {
+		log.Print("Problem getting channel info, skipping tvshow.nfo: ", err)
+	} else {
+		app.Download.TvShowNfo(channel)
 	}
 
-	app.YT.GetData()
-	app.Download.Videos()
+	return nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"download-youtube/getYTData"
+	"download-youtube/models"
+	"download-youtube/stateStore"
+)
+
+// Migrate copies the videos from the JSON file into a new SQLite database, the JSON file is left as it is
+func (app *App) Migrate() error {
+	jsonPath := stateStore.JSONPath(app.YT.EnvVar)
+	sqlitePath := stateStore.SQLitePath(app.YT.EnvVar)
+
+	videos, err := stateStore.NewJSONStore(jsonPath).Load()
+	if err != nil {
+		return err
+	}
+	if len(videos) == 0 {
+		return fmt.Errorf("no videos saved in %s", jsonPath)
+	}
+	// Older files can have the same video twice, they would be one row
+	videos = getYTData.BackfillIDs(videos)
+
+	db, err := stateStore.OpenSQLiteStore(sqlitePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	existing, err := db.Load()
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("%s already has %d videos, not migrating again", sqlitePath, len(existing))
+	}
+
+	if err := db.Save(videos); err != nil {
+		return fmt.Errorf("problem with saving the videos to %s: %w", sqlitePath, err)
+	}
+
+	migrated, err := db.Load()
+	if err != nil {
+		return err
+	}
+	if len(migrated) != len(videos) {
+		return fmt.Errorf("only %d of %d videos were saved to %s", len(migrated), len(videos), sqlitePath)
+	}
+
+	// Keeps the next sync incremental
+	lastSync, err := stateStore.NewJSONStore(jsonPath).LastSync()
+	if err == nil && !lastSync.IsZero() {
+		err = db.SetLastSync(lastSync)
+	}
+	if err != nil {
+		log.Printf("Problem migrating the time of the last sync: %v", err)
+	}
+
+	log.Printf("Migrated %d videos from %s to %s, set STATE_STORE=%s to use it", len(videos), jsonPath, sqlitePath, models.StoreSQLite)
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"download-youtube/getYTData"
+	"download-youtube/models"
+	"download-youtube/stateStore"
+)
+
+// TestMigrateDuplicates migrates a JSON file from an older version, with the same video saved twice
+func TestMigrateDuplicates(t *testing.T) {
+	envVar := models.EnvVar{SaveLoc: t.TempDir() + string(filepath.Separator), ChannelName: "Show"}
+	app := &App{YT: getYTData.YouTubeChannel{EnvVar: envVar}}
+
+	state := `[
+		{"title": "Old title", "url": "https://www.youtube.com/watch?v=abc", "downloaded": true, "imageSaved": true},
+		{"title": "Other", "id": "def", "url": "https://www.youtube.com/watch?v=def"},
+		{"title": "New title", "id": "abc", "url": "https://www.youtube.com/watch?v=abc"},
+		{"title": "Other", "id": "def", "url": "https://www.youtube.com/watch?v=def"}
+	]`
+	if err := os.WriteFile(stateStore.JSONPath(envVar), []byte(state), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := stateStore.OpenSQLiteStore(stateStore.SQLitePath(envVar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	videos, err := db.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(videos) != 2 {
+		t.Fatalf("migrated %d videos, want 2: %+v", len(videos), videos)
+	}
+	if videos[0].ID != "abc" || !videos[0].Downloaded || videos[0].Title != "New title" {
+		t.Errorf("first video = %+v, want the downloaded abc with the newest title", videos[0])
+	}
+	if videos[1].ID != "def" {
+		t.Errorf("second video = %+v, want def", videos[1])
+	}
+
+	if err := app.Migrate(); err == nil {
+		t.Error("migrated into a database that already has videos")
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+type Channel struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	AvatarURL   string `json:"avatarUrl"`
+	PublishedAt string `json:"publishedAt"`
+	Country     string `json:"country"`
+}
+
+// Show is the show a channel or playlist is saved as
+type Show struct {
+	Name    string  `json:"name"`
+	Channel Channel `json:"channel"`
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config lists every channel or playlist to archive, values set at the top level are used for all sources
+type Config struct {
+	ApiKey    string `json:"apiKey"`
+	SaveLoc   string `json:"saveLocation"`
+	Workers   int    `json:"workers"`
+	RateLimit string `json:"rateLimit"`
+	// TemplateDir has the NFO templates that replace the built-in ones, can also be set per source
+	TemplateDir string `json:"templateDir"`
+	// FilenameProfile is posix, windows or smb, the file system the library is saved on
+	FilenameProfile string `json:"filenameProfile"`
+	// StateStore is json or sqlite, where what is known about the videos is saved
+	StateStore string `json:"stateStore"`
+	// CacheTTL is how long a YouTube API response is used without asking YouTube again
+	CacheTTL string `json:"cacheTTL"`
+	// QuotaBudget is the most YouTube API quota units used in a day by all sources, 0 is no limit
+	QuotaBudget *int `json:"quotaBudget"`
+	// QuotaFile is where the quota used today is saved, shared by every source
+	QuotaFile string   `json:"quotaFile"`
+	Sources   []Source `json:"sources"`
+}
+
+// Source is a single channel or playlist saved as one show
+type Source struct {
+	ShowName        string        `json:"showName"`
+	ChannelID       string        `json:"channelId"`
+	PlaylistID      string        `json:"playlistId"`
+	SeasonStartYear int           `json:"seasonStartYear"`
+	SaveLoc         string        `json:"saveLocation"`
+	Numbering       string        `json:"numbering"`
+	TemplateDir     string        `json:"templateDir"`
+	FetchMode       string        `json:"fetchMode"`
+	SeasonStrategy  string        `json:"seasonStrategy"`
+	SeasonDates     []string      `json:"seasonDates"`
+	TitlePattern    string        `json:"titlePattern"`
+	TitleRules      []TitleRule   `json:"titleRules"`
+	Quality         QualityConfig `json:"quality"`
+}
+
+// QualityConfig is the format policy of a source, see FormatPolicy
+type QualityConfig struct {
+	MaxResolution string   `json:"maxResolution"`
+	VideoCodecs   []string `json:"videoCodecs"`
+	Containers    []string `json:"containers"`
+	AudioBitrate  int      `json:"audioBitrate"`
+	AllowMuxed    *bool    `json:"allowMuxed"`
+}
+
+// LoadConfig reads the config file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	configByte, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if err := json.Unmarshal(configByte, &config); err != nil {
+		return config, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+
+	if len(config.Sources) == 0 {
+		return config, fmt.Errorf("%w: %s has no sources", ErrInvalidConfig, path)
+	}
+
+	return config, nil
+}
+
+// EnvVar turns the source into the same values as a single channel .env file.
+// Anything not set on the source or the config is taken from base
+func (c Config) EnvVar(source Source, base EnvVar) EnvVar {
+	envVar := base
+
+	setString(&envVar.ApiKey, c.ApiKey)
+	setString(&envVar.SaveLoc, c.SaveLoc)
+	setString(&envVar.RateLimit, c.RateLimit)
+	setString(&envVar.TemplateDir, c.TemplateDir)
+	setString(&envVar.FilenameProfile, c.FilenameProfile)
+	setString(&envVar.StateStore, c.StateStore)
+	setString(&envVar.CacheTTL, c.CacheTTL)
+	setString(&envVar.QuotaFile, c.QuotaFile)
+	if c.Workers != 0 {
+		envVar.Workers = strconv.Itoa(c.Workers)
+	}
+	if c.QuotaBudget != nil {
+		envVar.QuotaBudget = strconv.Itoa(*c.QuotaBudget)
+	}
+
+	// A source is either a channel or a playlist, never use the one from base
+	envVar.ChannelID = source.ChannelID
+	envVar.PlaylistID = source.PlaylistID
+	envVar.ChannelName = source.ShowName
+
+	setString(&envVar.SaveLoc, source.SaveLoc)
+	setString(&envVar.Numbering, source.Numbering)
+	setString(&envVar.FetchMode, source.FetchMode)
+	setString(&envVar.TemplateDir, source.TemplateDir)
+	setString(&envVar.SeasonStrategy, source.SeasonStrategy)
+	setString(&envVar.SeasonDates, strings.Join(source.SeasonDates, ","))
+	setString(&envVar.TitlePattern, source.TitlePattern)
+	if len(source.TitleRules) > 0 {
+		rules, _ := json.Marshal(source.TitleRules)
+		envVar.TitleRules = string(rules)
+	}
+	if source.SeasonStartYear != 0 {
+		envVar.SeasonStartYear = strconv.Itoa(source.SeasonStartYear)
+	}
+
+	setString(&envVar.MaxResolution, source.Quality.MaxResolution)
+	setString(&envVar.VideoCodecs, strings.Join(source.Quality.VideoCodecs, ","))
+	setString(&envVar.Containers, strings.Join(source.Quality.Containers, ","))
+	if source.Quality.AudioBitrate != 0 {
+		envVar.AudioBitrate = strconv.Itoa(source.Quality.AudioBitrate)
+	}
+	if source.Quality.AllowMuxed != nil {
+		envVar.AllowMuxed = strconv.FormatBool(*source.Quality.AllowMuxed)
+	}
+
+	return envVar
+}
+
+// SourceEnvVars returns the values of every valid source, the invalid ones are reported per source in the error.
+// Local only checks what is needed to read the saved state
+func (c Config) SourceEnvVars(base EnvVar, local bool) ([]EnvVar, error) {
+	var envVars []EnvVar
+	var errs []error
+	for i, source := range c.Sources {
+		envVar := c.EnvVar(source, base)
+
+		validate := envVar.Validate
+		if local {
+			validate = envVar.ValidateLocal
+		}
+		if err := validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%w: source %d (%s): %v", ErrInvalidConfig, i+1, source.ShowName, err))
+			continue
+		}
+
+		envVars = append(envVars, envVar)
+	}
+	return envVars, errors.Join(errs...)
+}
+
+func setString(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
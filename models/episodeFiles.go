@@ -0,0 +1,24 @@
+package models
+
+import (
+	"fmt"
+	"os"
+)
+
+// EpisodeFileSuffixes are all files saved next to an episode, including the ones of an unfinished download
+var EpisodeFileSuffixes = []string{
+	".mp4", "-thumb.jpg", ".nfo",
+	"_video.mp4", "_audio.mp4",
+	".mp4.part.json", "_video.mp4.part.json", "_audio.mp4.part.json",
+}
+
+// MoveEpisodeFiles renames the files of an episode that exist
+func MoveEpisodeFiles(from, to string) error {
+	for _, suffix := range EpisodeFileSuffixes {
+		err := os.Rename(from+suffix, to+suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error renaming %s: %w", from+suffix, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package models
+
+import "errors"
+
+// Errors that end a run or an episode, wrapped so they can be checked with errors.Is
+var (
+	ErrQuotaExceeded     = errors.New("YouTube API quota exceeded")
+	ErrRateLimited       = errors.New("rate limited by YouTube")
+	ErrFormatUnavailable = errors.New("suitable format unavailable")
+	ErrFFmpegFailed      = errors.New("ffmpeg failed")
+	ErrStateCorrupt      = errors.New("state file corrupt")
+	ErrInvalidConfig     = errors.New("invalid config")
+)
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FilenameProfile has the rules for names on the file system the library is saved on
+type FilenameProfile string
+
+// Filename profiles
+const (
+	// FilenamePosix only replaces the path separator, names are limited to 255 bytes
+	FilenamePosix FilenameProfile = "posix"
+	// FilenameWindows follows NTFS, no reserved characters or device names, 255 UTF-16 characters per name and
+	// 260 for the whole path
+	FilenameWindows FilenameProfile = "windows"
+	// FilenameSMB is for a share served by Samba, the characters of Windows and the 255 bytes of the server
+	FilenameSMB FilenameProfile = "smb"
+)
+
+const (
+	maxNameLength    = 255
+	maxWindowsPath   = 259
+	minTruncatedName = 16
+)
+
+// derivedSuffix is the longest suffix added to an episode name while downloading, every name leaves room for it so
+// the video, thumbnail, NFO and stream files all fit
+const derivedSuffix = "_video.mp4.part.json"
+
+var (
+	posixReplacer   = strings.NewReplacer("/", "-")
+	windowsReplacer = strings.NewReplacer(
+		": ", " - ", ":", "-",
+		"/", "-", `\`, "-", "|", "-",
+		`"`, "'",
+		"*", "", "?", "", "<", "", ">", "",
+	)
+)
+
+// windowsReservedNames can not be used as a name on Windows, also not with an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Valid reports if the profile is known, empty is posix
+func (p FilenameProfile) Valid() bool {
+	switch p {
+	case "", FilenamePosix, FilenameWindows, FilenameSMB:
+		return true
+	}
+	return false
+}
+
+// Filename makes name safe to use as a file in dir. Too long names are shortened and get a hash of the full name,
+// so the same title always gives the same file
+func (p FilenameProfile) Filename(dir, name string) string {
+	name = norm.NFC.String(name)
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, name)
+
+	if p == FilenameWindows || p == FilenameSMB {
+		name = windowsReplacer.Replace(name)
+	} else {
+		name = posixReplacer.Replace(name)
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	name = p.trim(name)
+
+	if p == FilenameWindows || p == FilenameSMB {
+		base, _, _ := strings.Cut(name, ".")
+		if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+			name = "_" + name
+		}
+	}
+	if name == "" || name == "." || name == ".." {
+		name = "_"
+	}
+
+	return p.truncate(dir, name)
+}
+
+// trim removes the spaces around the name, Windows also drops trailing dots
+func (p FilenameProfile) trim(name string) string {
+	if p == FilenameWindows || p == FilenameSMB {
+		return strings.TrimRight(strings.TrimSpace(name), ". ")
+	}
+	return strings.TrimSpace(name)
+}
+
+// length counts the name the way the file system limits it
+func (p FilenameProfile) length(name string) int {
+	if p == FilenameWindows {
+		return len(utf16.Encode([]rune(name)))
+	}
+	return len(name)
+}
+
+// truncate shortens the name to fit, leaving room for derivedSuffix
+func (p FilenameProfile) truncate(dir, name string) string {
+	limit := maxNameLength - p.length(derivedSuffix)
+	if p == FilenameWindows {
+		limit = min(limit, maxWindowsPath-p.length(dir)-1-p.length(derivedSuffix))
+	}
+	limit = max(limit, minTruncatedName)
+
+	if p.length(name) <= limit {
+		return name
+	}
+
+	hash := fmt.Sprintf(" ~%x", sha1.Sum([]byte(name)))[:10]
+	short := name
+	for short != "" && p.length(short)+len(hash) > limit {
+		_, size := utf8.DecodeLastRuneInString(short)
+		short = short[:len(short)-size]
+	}
+
+	return p.trim(short) + hash
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func TestFilename(t *testing.T) {
+	tests := []struct {
+		profile FilenameProfile
+		name    string
+		want    string
+	}{
+		{FilenamePosix, "S01E01 - AC/DC: Live?", "S01E01 - AC-DC: Live?"},
+		{FilenameWindows, "S01E01 - AC/DC: Live?", "S01E01 - AC-DC - Live"},
+		{FilenameSMB, "S01E01 - AC/DC: Live?", "S01E01 - AC-DC - Live"},
+		{FilenameWindows, `Say "hi" <now> | a\b *twice*`, "Say 'hi' now - a-b twice"},
+		{FilenameWindows, "Time:12", "Time-12"},
+
+		// Trailing dots and spaces
+		{FilenamePosix, "Wait for it...", "Wait for it..."},
+		{FilenameWindows, "Wait for it... ", "Wait for it"},
+		{FilenameSMB, "Wait for it. . .", "Wait for it"},
+
+		// Reserved Windows names, also with an extension
+		{FilenamePosix, "CON", "CON"},
+		{FilenameWindows, "CON", "_CON"},
+		{FilenameWindows, "con.mp4", "_con.mp4"},
+		{FilenameSMB, " LPT1 ", "_LPT1"},
+		{FilenameWindows, "CONSOLE", "CONSOLE"},
+		{FilenameWindows, "COM10", "COM10"},
+
+		// Control characters, white space and Unicode normalization
+		{FilenamePosix, "Tab\there\nand\r\nnew  lines", "Tab here and new lines"},
+		{FilenamePosix, "Cafe\u0301", "Caf\u00e9"},
+
+		// Names that are left empty
+		{FilenamePosix, "", "_"},
+		{FilenamePosix, "..", "_"},
+		{FilenameWindows, "...", "_"},
+		{FilenameWindows, "???", "_"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.profile)+"/"+test.name, func(t *testing.T) {
+			if got := test.profile.Filename("/library/Show/Season 01", test.name); got != test.want {
+				t.Errorf("Filename(%q) = %q, want %q", test.name, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilenameTruncate(t *testing.T) {
+	bytes := func(name string) int { return len(name) }
+	utf16Units := func(name string) int { return len(utf16.Encode([]rune(name))) }
+
+	tests := []struct {
+		name    string
+		profile FilenameProfile
+		dir     string
+		title   string
+		length  func(string) int
+		limit   int
+	}{
+		{"posix ascii", FilenamePosix, "/library", strings.Repeat("a", 300), bytes, maxNameLength - len(derivedSuffix)},
+		{"posix multi-byte", FilenamePosix, "/library", strings.Repeat("\u00e9", 300), bytes, maxNameLength - len(derivedSuffix)},
+		{"posix emoji", FilenamePosix, "/library", strings.Repeat("\U0001F600", 100), bytes, maxNameLength - len(derivedSuffix)},
+		{"smb multi-byte", FilenameSMB, "/mnt/share", strings.Repeat("\u00e9", 300), bytes, maxNameLength - len(derivedSuffix)},
+		{"windows short dir", FilenameWindows, `D:\Shows\Season 01`, strings.Repeat("\u00e9", 300), utf16Units, maxWindowsPath - 18 - 1 - len(derivedSuffix)},
+		{"windows emoji", FilenameWindows, `D:\Shows\Season 01`, strings.Repeat("\U0001F600", 200), utf16Units, maxWindowsPath - 18 - 1 - len(derivedSuffix)},
+		{"windows long dir", FilenameWindows, `D:\` + strings.Repeat("d", 300), strings.Repeat("a", 300), utf16Units, minTruncatedName},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.profile.Filename(test.dir, test.title)
+
+			if !utf8.ValidString(got) {
+				t.Fatalf("%q is not valid UTF-8", got)
+			}
+			if length := test.length(got); length > test.limit {
+				t.Errorf("length %d is over the limit of %d: %q", length, test.limit, got)
+			}
+			if !strings.Contains(got, " ~") {
+				t.Errorf("%q has no hash of the full name", got)
+			}
+			if again := test.profile.Filename(test.dir, test.title); again != got {
+				t.Errorf("same title gave %q and %q", got, again)
+			}
+			if other := test.profile.Filename(test.dir, test.title+"b"); other == got {
+				t.Errorf("different titles both gave %q", got)
+			}
+		})
+	}
+}
+
+func TestFilenameShortNamesAreKept(t *testing.T) {
+	name := strings.Repeat("a", maxNameLength-len(derivedSuffix))
+	if got := FilenamePosix.Filename("/library", name); got != name {
+		t.Errorf("name at the limit was changed to %q", got)
+	}
+}
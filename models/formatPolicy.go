@@ -0,0 +1,87 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// resolutions are the heights YouTube offers, from best to worst
+var resolutions = []int{4320, 2160, 1440, 1080, 720, 480, 360, 240, 144}
+
+// FormatPolicy decides which video and audio format is downloaded for an episode
+type FormatPolicy struct {
+	// Resolutions to try in order, starting at the preferred max resolution
+	Resolutions []int
+	// VideoCodecs in order of preference, e.g. avc1, vp9, av01
+	VideoCodecs []string
+	// Containers in order of preference, e.g. mp4, webm
+	Containers []string
+	// AudioBitrate is the preferred audio bitrate in kbps, the closest one at or below it is used
+	AudioBitrate int
+	// AllowMuxed accepts progressive formats that already have both audio and video
+	AllowMuxed bool
+}
+
+// FormatPolicy builds the policy from the env values, using the defaults for anything not set
+func (e EnvVar) FormatPolicy() (FormatPolicy, error) {
+	policy := FormatPolicy{
+		VideoCodecs:  []string{"avc1", "vp9", "av01"},
+		Containers:   []string{"mp4", "webm"},
+		AudioBitrate: 128,
+	}
+
+	maxResolution := 720
+	if e.MaxResolution != "" {
+		var err error
+		maxResolution, err = strconv.Atoi(strings.TrimSuffix(e.MaxResolution, "p"))
+		if err != nil || maxResolution < 1 {
+			return policy, fmt.Errorf("invalid MAX_RESOLUTION %q: must be a height like 1080p", e.MaxResolution)
+		}
+	}
+	for _, resolution := range resolutions {
+		if resolution <= maxResolution {
+			policy.Resolutions = append(policy.Resolutions, resolution)
+		}
+	}
+	if len(policy.Resolutions) == 0 {
+		return policy, fmt.Errorf("invalid MAX_RESOLUTION %q: lowest available is 144p", e.MaxResolution)
+	}
+
+	if e.VideoCodecs != "" {
+		policy.VideoCodecs = splitList(e.VideoCodecs)
+	}
+	if e.Containers != "" {
+		policy.Containers = splitList(e.Containers)
+	}
+
+	if e.AudioBitrate != "" {
+		bitrate, err := strconv.Atoi(strings.TrimSuffix(e.AudioBitrate, "k"))
+		if err != nil || bitrate < 1 {
+			return policy, fmt.Errorf("invalid AUDIO_BITRATE %q: must be kbps like 128", e.AudioBitrate)
+		}
+		policy.AudioBitrate = bitrate
+	}
+
+	if e.AllowMuxed != "" {
+		allowMuxed, err := strconv.ParseBool(e.AllowMuxed)
+		if err != nil {
+			return policy, fmt.Errorf("invalid ALLOW_MUXED %q: must be true or false", e.AllowMuxed)
+		}
+		policy.AllowMuxed = allowMuxed
+	}
+
+	return policy, nil
+}
+
+// splitList splits a comma separated value into lower case entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
@@ -0,0 +1,13 @@
+package models
+
+// MediaInfo is what ffprobe found in the downloaded file
+type MediaInfo struct {
+	VideoCodec    string  `json:"videoCodec"`
+	Width         int     `json:"width"`
+	Height        int     `json:"height"`
+	Aspect        float64 `json:"aspect"`
+	Duration      float64 `json:"duration"`
+	AudioCodec    string  `json:"audioCodec"`
+	AudioChannels int     `json:"audioChannels"`
+	AudioLanguage string  `json:"audioLanguage"`
+}
@@ -2,7 +2,47 @@ package models
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+)
+
+// Numbering modes, how the season and episode of a video is decided
+const (
+	// NumberingAuto reads it from the title when it has both episode and season in it, otherwise by date
+	NumberingAuto = "auto"
+	// NumberingDate always uses the year it was published as season
+	NumberingDate = "date"
+)
+
+// Fetch modes, how the videos of a channel are listed
+const (
+	// FetchUploads pages through the uploads playlist of the channel, 1 quota unit per page
+	FetchUploads = "uploads"
+	// FetchSearch uses search, 100 quota units per page and stops at around 500 videos
+	FetchSearch = "search"
+)
+
+// Season strategies, how the season of a video is decided when it is not read from the title
+const (
+	// SeasonsByYear uses the year it was published, counting from the season start year
+	SeasonsByYear = "year"
+	// SeasonsFixed puts every video in season 1
+	SeasonsFixed = "fixed"
+	// SeasonsByPlaylist has a season for every playlist of the channel, oldest playlist first
+	SeasonsByPlaylist = "playlist"
+	// SeasonsByDates starts a new season at each of the season dates
+	SeasonsByDates = "dates"
+	// SeasonsByTitle reads the season from the title with the title pattern
+	SeasonsByTitle = "regex"
+)
+
+// State stores, where what is known about the videos is saved
+const (
+	// StoreJSON is one JSON file per show
+	StoreJSON = "json"
+	// StoreSQLite is one SQLite database per show
+	StoreSQLite = "sqlite"
 )
 
 type EnvVar struct {
@@ -12,6 +52,25 @@ type EnvVar struct {
 	ChannelName     string
 	SeasonStartYear string
 	SaveLoc         string
+	Workers         string
+	RateLimit       string
+	MaxResolution   string
+	VideoCodecs     string
+	Containers      string
+	AudioBitrate    string
+	AllowMuxed      string
+	Numbering       string
+	FetchMode       string
+	TemplateDir     string
+	FilenameProfile string
+	SeasonStrategy  string
+	SeasonDates     string
+	TitlePattern    string
+	TitleRules      string
+	StateStore      string
+	CacheTTL        string
+	QuotaBudget     string
+	QuotaFile       string
 }
 
 func (e EnvVar) Validate() error {
@@ -26,10 +85,59 @@ func (e EnvVar) Validate() error {
 	if e.ChannelName == "" {
 		missingFields = append(missingFields, "YT_CHANNEL_NAME")
 	}
-	if e.SeasonStartYear == "" {
+	if e.SeasonStartYear == "" && e.UsesStartYear() {
 		missingFields = append(missingFields, "SEASON_START_YEAR")
 	}
 
+	if e.Workers != "" {
+		if workers, err := strconv.Atoi(e.Workers); err != nil || workers < 1 {
+			return fmt.Errorf("invalid DOWNLOAD_WORKERS %q: must be a number above 0", e.Workers)
+		}
+	}
+	if e.RateLimit != "" {
+		if _, err := time.ParseDuration(e.RateLimit); err != nil {
+			return fmt.Errorf("invalid DOWNLOAD_RATE_LIMIT %q: %v", e.RateLimit, err)
+		}
+	}
+	if e.CacheTTL != "" {
+		if ttl, err := time.ParseDuration(e.CacheTTL); err != nil || ttl < 0 {
+			return fmt.Errorf("invalid API_CACHE_TTL %q: must be a duration of 0 or more", e.CacheTTL)
+		}
+	}
+	if e.QuotaBudget != "" {
+		if budget, err := strconv.Atoi(e.QuotaBudget); err != nil || budget < 0 {
+			return fmt.Errorf("invalid API_QUOTA_BUDGET %q: must be a number of 0 or more", e.QuotaBudget)
+		}
+	}
+
+	switch e.Numbering {
+	case "", NumberingAuto, NumberingDate:
+	default:
+		return fmt.Errorf("invalid NUMBERING_MODE %q: must be %s or %s", e.Numbering, NumberingAuto, NumberingDate)
+	}
+
+	switch e.FetchMode {
+	case "", FetchUploads, FetchSearch:
+	default:
+		return fmt.Errorf("invalid FETCH_MODE %q: must be %s or %s", e.FetchMode, FetchUploads, FetchSearch)
+	}
+
+	if err := e.validateStateStore(); err != nil {
+		return err
+	}
+
+	if err := e.validateSeasons(); err != nil {
+		return err
+	}
+
+	if !FilenameProfile(e.FilenameProfile).Valid() {
+		return fmt.Errorf("invalid FILENAME_PROFILE %q: must be %s, %s or %s", e.FilenameProfile, FilenamePosix, FilenameWindows, FilenameSMB)
+	}
+
+	if _, err := e.FormatPolicy(); err != nil {
+		return err
+	}
+
 	// If there are missing fields, return a combined error
 	if len(missingFields) > 0 {
 		return fmt.Errorf("missing environment variables: %s", strings.Join(missingFields, ", "))
@@ -38,6 +146,23 @@ func (e EnvVar) Validate() error {
 	return nil
 }
 
+// ValidateLocal only checks what is needed to read the saved state, without talking to the YouTube API
+func (e EnvVar) ValidateLocal() error {
+	if e.ChannelName == "" {
+		return fmt.Errorf("missing environment variables: YT_CHANNEL_NAME")
+	}
+	return e.validateStateStore()
+}
+
+func (e EnvVar) validateStateStore() error {
+	switch e.StateStore {
+	case "", StoreJSON, StoreSQLite:
+		return nil
+	default:
+		return fmt.Errorf("invalid STATE_STORE %q: must be %s or %s", e.StateStore, StoreJSON, StoreSQLite)
+	}
+}
+
 type NFOEpisodeDetails struct {
 	CreationDate     string
 	Version          string
@@ -50,10 +175,14 @@ type NFOEpisodeDetails struct {
 	DisplayEpisode   string
 	ID               string
 	Ratings          string
+	RatingValue      string
+	RatingVotes      string
 	UserRating       string
 	Plot             string
 	Runtime          string
 	MPAA             string
+	Genres           []string
+	Tags             []string
 	Premiered        string
 	Aired            string
 	Watched          string
@@ -68,6 +197,9 @@ type NFOEpisodeDetails struct {
 	VideoHeight      string
 	VideoDuration    string
 	StereoMode       string
+	AudioCodec       string
+	AudioLanguage    string
+	AudioChannels    string
 	Source           string
 	OriginalFilename string
 	UserNote         string
@@ -76,3 +208,37 @@ type NFOEpisodeDetails struct {
 	GroupName        string
 	GroupSeason      string
 }
+
+type NFOTvShowDetails struct {
+	Title          string
+	OriginalTitle  string
+	ShowTitle      string
+	SortTitle      string
+	Year           string
+	Ratings        string
+	UserRating     string
+	Outline        string
+	Plot           string
+	Tagline        string
+	Premiered      string
+	Status         string
+	Watched        string
+	PlayCount      string
+	Genre          string
+	Studio         string
+	Country        string
+	ActorName      string
+	ActorRole      string
+	ActorThumbnail string
+	Trailer        string
+	DateAdded      string
+	Season         string
+	UserNote       string
+}
+
+type NFOSeasonDetails struct {
+	Title        string
+	Year         string
+	SeasonNumber string
+	Plot         string
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// SeasonDateLayout is the layout of the dates in SEASON_DATES
+const SeasonDateLayout = "2006-01-02"
+
+// SeasonStarts parses the comma separated start dates of the seasons, they have to be in order
+func (e EnvVar) SeasonStarts() ([]time.Time, error) {
+	var dates []time.Time
+	for _, value := range splitList(e.SeasonDates) {
+		date, err := time.Parse(SeasonDateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SEASON_DATES %q: %v", value, err)
+		}
+		if len(dates) > 0 && !date.After(dates[len(dates)-1]) {
+			return nil, fmt.Errorf("invalid SEASON_DATES: %s is not after the season before it", value)
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
+
+// TitleRegexp compiles the title pattern, it needs a named group for the season and can have ones for the title and
+// episode
+func (e EnvVar) TitleRegexp() (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(e.TitlePattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TITLE_PATTERN: %v", err)
+	}
+	if !slices.Contains(pattern.SubexpNames(), "season") {
+		return nil, fmt.Errorf("invalid TITLE_PATTERN %q: needs a (?P<season>...) group", e.TitlePattern)
+	}
+	return pattern, nil
+}
+
+// UsesStartYear is true for the season strategies that number the seasons from SEASON_START_YEAR
+func (e EnvVar) UsesStartYear() bool {
+	switch e.SeasonStrategy {
+	case "", SeasonsByYear, SeasonsByTitle:
+		return true
+	}
+	return false
+}
+
+func (e EnvVar) validateSeasons() error {
+	if _, err := e.ParseTitleRules(); err != nil {
+		return err
+	}
+
+	switch e.SeasonStrategy {
+	case "", SeasonsByYear, SeasonsFixed, SeasonsByPlaylist:
+	case SeasonsByDates:
+		dates, err := e.SeasonStarts()
+		if err != nil {
+			return err
+		}
+		if len(dates) == 0 {
+			return fmt.Errorf("missing SEASON_DATES for SEASON_STRATEGY %s", SeasonsByDates)
+		}
+	case SeasonsByTitle:
+		if e.TitlePattern == "" {
+			return fmt.Errorf("missing TITLE_PATTERN for SEASON_STRATEGY %s", SeasonsByTitle)
+		}
+		if _, err := e.TitleRegexp(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid SEASON_STRATEGY %q: must be %s, %s, %s, %s or %s",
+			e.SeasonStrategy, SeasonsByYear, SeasonsFixed, SeasonsByPlaylist, SeasonsByDates, SeasonsByTitle)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateBackups is how many backups of a state file are kept, a backup is made the first time the file is written
+// in a run, so they are the state from before each of the last runs
+const StateBackups = 5
+
+// backedUp has the state files already backed up in this run
+var backedUp sync.Map
+
+// StateBackupPath is the path of the nth backup of a state file, 1 is the newest
+func StateBackupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// ReadState reads a state file and checks it is valid JSON. A missing file returns an error matching
+// os.ErrNotExist, unless there are backups of it, then the state is treated as corrupt instead of starting over
+func ReadState(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if backup := newestBackup(path); backup != "" {
+			return nil, fmt.Errorf("%w: %s is missing but has a backup, restore %s to continue", ErrStateCorrupt, path, backup)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !json.Valid(content) {
+		if backup := newestBackup(path); backup != "" {
+			return nil, fmt.Errorf("%w: %s is not valid JSON, the last good state is in %s", ErrStateCorrupt, path, backup)
+		}
+		return nil, fmt.Errorf("%w: %s is not valid JSON", ErrStateCorrupt, path)
+	}
+
+	return content, nil
+}
+
+// WriteState replaces a state file without ever leaving a partly written one. The content goes to a temp file in
+// the same folder, which is synced to disk and renamed over the old file. The old file is backed up once per run
+func WriteState(path string, content []byte, backups int) error {
+	if backups > 0 {
+		if _, done := backedUp.LoadOrStore(path, true); !done {
+			if err := rotateBackups(path, backups); err != nil {
+				return fmt.Errorf("error backing up %s: %w", path, err)
+			}
+		}
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// The rename is only durable once the folder is synced, not supported on every OS
+	if folder, err := os.Open(dir); err == nil {
+		_ = folder.Sync()
+		folder.Close()
+	}
+
+	return nil
+}
+
+// rotateBackups shifts the backups one up and copies the current file to the first one. A current file that is not
+// valid JSON is never backed up, so the backups are always good states
+func rotateBackups(path string, backups int) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !json.Valid(content) {
+		return nil
+	}
+
+	for n := backups - 1; n >= 1; n-- {
+		err := os.Rename(StateBackupPath(path, n), StateBackupPath(path, n+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return WriteState(StateBackupPath(path, 1), content, 0)
+}
+
+// newestBackup finds the newest backup that is valid JSON
+func newestBackup(path string) string {
+	for n := 1; n <= StateBackups; n++ {
+		content, err := os.ReadFile(StateBackupPath(path, n))
+		if err == nil && json.Valid(content) {
+			return StateBackupPath(path, n)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRun writes the state as a new run would, which backs up the file again
+func writeRun(t *testing.T, path, content string, backups int) {
+	t.Helper()
+	backedUp.Delete(path)
+	if err := WriteState(path, []byte(content), backups); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWriteStateRotatesBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	for _, content := range []string{`[1]`, `[2]`, `[3]`, `[4]`, `[5]`} {
+		writeRun(t, path, content, 3)
+	}
+	// A second write in the same run is not backed up again
+	if err := WriteState(path, []byte(`[6]`), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path:                     `[6]`,
+		StateBackupPath(path, 1): `[4]`,
+		StateBackupPath(path, 2): `[3]`,
+		StateBackupPath(path, 3): `[2]`,
+		StateBackupPath(path, 4): ``,
+	}
+	for file, content := range want {
+		if got := readFile(t, file); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, content)
+		}
+	}
+
+	// A broken file is not backed up over the good states
+	if err := os.WriteFile(path, []byte(`[7`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeRun(t, path, `[8]`, 3)
+	if got := readFile(t, StateBackupPath(path, 1)); got != `[4]` {
+		t.Errorf("backup after a broken file = %q, want [4]", got)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp*"))
+	if len(matches) > 0 {
+		t.Errorf("temp files left behind: %q", matches)
+	}
+}
+
+func TestReadState(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		backup  string
+		// wantErr is nil for a good state
+		wantErr error
+		// wantMsg is part of the error
+		wantMsg string
+	}{
+		{name: "valid", content: `[{"id":"a"}]`},
+		{name: "missing", wantErr: os.ErrNotExist},
+		{name: "missing with a backup", backup: `[]`, wantErr: ErrStateCorrupt, wantMsg: "restore"},
+		{name: "truncated", content: `[{"id":"a"`, wantErr: ErrStateCorrupt, wantMsg: "not valid JSON"},
+		{name: "truncated with a backup", content: `[{"id":"a"`, backup: `[]`, wantErr: ErrStateCorrupt, wantMsg: ".1"},
+		{name: "truncated with a broken backup", content: `[{"id":"a"`, backup: `[`, wantErr: ErrStateCorrupt, wantMsg: "not valid JSON"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "state.json")
+			if test.content != "" {
+				if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.backup != "" {
+				if err := os.WriteFile(StateBackupPath(path, 1), []byte(test.backup), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			content, err := ReadState(path)
+			if test.wantErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(content) != test.content {
+					t.Errorf("read %q, want %q", content, test.content)
+				}
+				return
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("error %v, want %v", err, test.wantErr)
+			}
+			if !strings.Contains(err.Error(), test.wantMsg) {
+				t.Errorf("error %q does not mention %q", err, test.wantMsg)
+			}
+			if test.backup == `[` && strings.Contains(err.Error(), StateBackupPath(path, 1)) {
+				t.Errorf("error %q suggests a broken backup", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TitleRule reads the season, episode and title of a video from its title with a regex with the named groups
+// season, episode and title. Rules are tried in order, the first one that matches is used
+type TitleRule struct {
+	Pattern string `json:"pattern"`
+	// Cleanup is applied in order to the title after the rule matched
+	Cleanup []TitleCleanup `json:"cleanup,omitempty"`
+}
+
+// TitleCleanup replaces every match of the pattern, the replacement can use the groups, e.g. $1
+type TitleCleanup struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+// ParseTitleRules reads TITLE_RULES, either the rules as JSON or the path to a JSON file with them
+func (e EnvVar) ParseTitleRules() ([]TitleRule, error) {
+	if e.TitleRules == "" {
+		return nil, nil
+	}
+
+	rulesJSON := []byte(e.TitleRules)
+	if !strings.HasPrefix(strings.TrimSpace(e.TitleRules), "[") {
+		var err error
+		rulesJSON, err = os.ReadFile(e.TitleRules)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TITLE_RULES: %v", err)
+		}
+	}
+
+	var rules []TitleRule
+	if err := json.Unmarshal(rulesJSON, &rules); err != nil {
+		return nil, fmt.Errorf("invalid TITLE_RULES: %v", err)
+	}
+
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TITLE_RULES rule %d: %v", i+1, err)
+		}
+		groups := pattern.SubexpNames()
+		if !slices.Contains(groups, "season") && !slices.Contains(groups, "episode") {
+			return nil, fmt.Errorf("invalid TITLE_RULES rule %d %q: needs a (?P<season>...) or (?P<episode>...) group", i+1, rule.Pattern)
+		}
+		for _, cleanup := range rule.Cleanup {
+			if _, err := regexp.Compile(cleanup.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid TITLE_RULES rule %d cleanup: %v", i+1, err)
+			}
+		}
+	}
+
+	return rules, nil
+}
@@ -10,9 +10,33 @@ type Video struct {
 	ChannelTitle string `json:"channelTitle"`
 	Season       string `json:"season"`
 	Episode      string `json:"episode"`
-	Downloaded   bool   `json:"downloaded"`
-	ImageSaved   bool   `json:"imageSaved"`
-	Filename     string `json:"filename"`
-	Filepath     string `json:"filepath"`
-	Error        string `json:"error"`
+	// NumberedFromTitle is set when the season and episode were read from the title, renumber keeps them
+	NumberedFromTitle bool     `json:"numberedFromTitle,omitempty"`
+	Downloaded        bool     `json:"downloaded"`
+	ImageSaved        bool     `json:"imageSaved"`
+	Filename          string   `json:"filename"`
+	Filepath          string   `json:"filepath"`
+	Error             string   `json:"error"`
+	Duration          int      `json:"duration"`
+	Definition        string   `json:"definition"`
+	Tags              []string `json:"tags"`
+	CategoryID        string   `json:"categoryId"`
+	Category          string   `json:"category"`
+	AudioLanguage     string   `json:"audioLanguage"`
+	ViewCount         int64    `json:"viewCount"`
+	LikeCount         int64    `json:"likeCount"`
+	CommentCount      int64    `json:"commentCount"`
+	// DetailsFetchedAt is when the details were last fetched, also when YouTube did not have any
+	DetailsFetchedAt string     `json:"detailsFetchedAt,omitempty"`
+	Media            *MediaInfo `json:"media,omitempty"`
+	// Changes are the titles, descriptions and thumbnails the video had before, oldest first
+	Changes []MetadataChange `json:"changes,omitempty"`
+}
+
+// MetadataChange is a field of a known video that was changed on YouTube
+type MetadataChange struct {
+	Field      string `json:"field"`
+	Old        string `json:"old"`
+	New        string `json:"new"`
+	DetectedAt string `json:"detectedAt"`
 }
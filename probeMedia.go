@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"download-youtube/models"
+)
+
+// ffprobeOutput is the part of `ffprobe -print_format json -show_streams -show_format` we use
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType          string `json:"codec_type"`
+		CodecName          string `json:"codec_name"`
+		Width              int    `json:"width"`
+		Height             int    `json:"height"`
+		DisplayAspectRatio string `json:"display_aspect_ratio"`
+		Channels           int    `json:"channels"`
+		Tags               struct {
+			Language string `json:"language"`
+		} `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probeMedia uses ffprobe to find the codecs, resolution, duration and audio of the downloaded file
+func probeMedia(filename string) (*models.MediaInfo, error) {
+	ffprobeCmd := exec.Command("ffprobe", "-v", "quiet", "-print_format", "json", "-show_streams", "-show_format", filename)
+
+	output, err := ffprobeCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: error probing %s: %s", models.ErrFFmpegFailed, filename, err)
+	}
+
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("error reading ffprobe output for %s: %v", filename, err)
+	}
+
+	var media models.MediaInfo
+	media.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			if media.VideoCodec != "" {
+				continue
+			}
+			media.VideoCodec = stream.CodecName
+			media.Width = stream.Width
+			media.Height = stream.Height
+			media.Aspect = aspectRatio(stream.DisplayAspectRatio, stream.Width, stream.Height)
+		case "audio":
+			if media.AudioCodec != "" {
+				continue
+			}
+			media.AudioCodec = stream.CodecName
+			media.AudioChannels = stream.Channels
+			if stream.Tags.Language != "und" {
+				media.AudioLanguage = stream.Tags.Language
+			}
+		}
+	}
+
+	log.Printf("Probed %s: %s %dx%d, %s %d channels", filename, media.VideoCodec, media.Width, media.Height, media.AudioCodec, media.AudioChannels)
+
+	return &media, nil
+}
+
+// aspectRatio reads the display aspect ratio, e.g. "16:9", falling back to the width and height
+func aspectRatio(displayAspectRatio string, width, height int) float64 {
+	if w, h, found := strings.Cut(displayAspectRatio, ":"); found {
+		aspectWidth, errW := strconv.ParseFloat(w, 64)
+		aspectHeight, errH := strconv.ParseFloat(h, 64)
+		if errW == nil && errH == nil && aspectHeight > 0 && aspectWidth > 0 {
+			return aspectWidth / aspectHeight
+		}
+	}
+	if height == 0 {
+		return 0
+	}
+	return float64(width) / float64(height)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// rateLimitedTransport makes sure requests towards YouTube are spread out, shared between all download workers
+// to not end up on the google.com/sorry page
+type rateLimitedTransport struct {
+	ticker *time.Ticker
+	next   http.RoundTripper
+}
+
+// newRateLimitedTransport allows one request per interval, an interval of 0 disables the limit
+func newRateLimitedTransport(interval time.Duration, next http.RoundTripper) *rateLimitedTransport {
+	transport := &rateLimitedTransport{next: next}
+	if interval > 0 {
+		transport.ticker = time.NewTicker(interval)
+	}
+	return transport
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.ticker != nil {
+		select {
+		case <-t.ticker.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Stop releases the ticker once all downloads are done
+func (t *rateLimitedTransport) Stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"download-youtube/getYTData"
+	"download-youtube/models"
+)
+
+// renumberSuffix is added to the files while renaming, so episodes can swap numbers
+const renumberSuffix = ".renumber"
+
+// episodeRename is an episode that got a new number
+type episodeRename struct {
+	Index int
+	From  string
+	To    string
+}
+
+// Renumber numbers the episodes of every season again by publish date and renames their files.
+// The NFOs are re-written after, keeping what the user has edited
+func (app *App) Renumber() error {
+	videos, err := app.Download.Store.Load()
+	if err != nil {
+		return err
+	}
+	original := make([]models.Video, len(videos))
+	copy(original, videos)
+
+	changed := getYTData.RenumberEpisodes(videos)
+	if len(changed) == 0 {
+		log.Print("All episodes are numbered already")
+		return nil
+	}
+
+	var renames []episodeRename
+	for _, i := range changed {
+		from := videos[i].Filepath
+		videos[i] = app.YT.FilePathAndName(videos[i])
+		renames = append(renames, episodeRename{Index: i, From: from, To: videos[i].Filepath})
+	}
+
+	var errs []error
+
+	// Move everything out of the way first, an episode can get the number another one had
+	var moved []episodeRename
+	for _, rename := range renames {
+		if err := models.MoveEpisodeFiles(rename.From, rename.From+renumberSuffix); err != nil {
+			errs = append(errs, err)
+			_ = models.MoveEpisodeFiles(rename.From+renumberSuffix, rename.From)
+			videos[rename.Index] = original[rename.Index]
+			continue
+		}
+		moved = append(moved, rename)
+	}
+
+	// The name of an episode can be the new name of another one
+	taken := make(map[string]bool, len(moved))
+	for _, rename := range moved {
+		taken[rename.To] = true
+	}
+
+	renamed := 0
+	for _, rename := range moved {
+		if err := models.MoveEpisodeFiles(rename.From+renumberSuffix, rename.To); err != nil {
+			errs = append(errs, err)
+			videos[rename.Index] = original[rename.Index]
+			// The files already moved go back, unless another episode gets the old name
+			_ = models.MoveEpisodeFiles(rename.To, rename.From+renumberSuffix)
+			if taken[rename.From] {
+				videos[rename.Index].Filepath = rename.From + renumberSuffix
+				errs = append(errs, fmt.Errorf("files of %s are left at %s, another episode gets its name",
+					rename.From, rename.From+renumberSuffix))
+			} else if err := models.MoveEpisodeFiles(rename.From+renumberSuffix, rename.From); err != nil {
+				videos[rename.Index].Filepath = rename.From + renumberSuffix
+				errs = append(errs, err)
+			}
+			continue
+		}
+		renamed++
+		log.Printf("Renamed %s -> %s", rename.From, rename.To)
+	}
+
+	if err := app.Download.Store.Save(videos); err != nil {
+		return fmt.Errorf("problem with saving the videos: %w", err)
+	}
+	log.Printf("Renumbered %d episodes", renamed)
+
+	if err := app.Download.RefreshNfos(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"download-youtube/models"
+
+	"github.com/kkdai/youtube/v2"
+)
+
+// streamChunkSize is how much is requested per Range request, a failed request only loses the chunk
+const streamChunkSize = 10 * 1024 * 1024
+
+// streamIdleTimeout is how long a Range request may go without receiving anything. The total timeout of the client
+// is not used for streams, a slow connection would not finish a chunk in time
+const streamIdleTimeout = 30 * time.Second
+
+// partialStream is saved next to a partially downloaded stream, so the next run can continue where it stopped
+type partialStream struct {
+	Itag          int   `json:"itag"`
+	ContentLength int64 `json:"contentLength"`
+}
+
+func sidecarPath(filename string) string {
+	return filename + ".part.json"
+}
+
+// resumeOffset returns how many bytes of the stream are already downloaded, the content length when it is complete.
+// If the partial file belongs to another format it starts over from zero
+func resumeOffset(filename string, format *youtube.Format) (int64, error) {
+	sidecar := partialStream{Itag: format.ItagNo, ContentLength: format.ContentLength}
+
+	var existing partialStream
+	sidecarByte, err := os.ReadFile(sidecarPath(filename))
+	if err == nil && json.Unmarshal(sidecarByte, &existing) == nil && existing == sidecar {
+		info, err := os.Stat(filename)
+		if err == nil && info.Size() == format.ContentLength {
+			log.Printf("Already downloaded %s", filename)
+			return info.Size(), nil
+		}
+		if err == nil && info.Size() < format.ContentLength {
+			log.Printf("Resuming %s from %d of %d bytes", filename, info.Size(), format.ContentLength)
+			return info.Size(), nil
+		}
+	}
+
+	if err := os.Truncate(filename, 0); err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("reset partial file - %s", err)
+	}
+
+	sidecarByte, _ = json.Marshal(sidecar)
+	if err := os.WriteFile(sidecarPath(filename), sidecarByte, 0644); err != nil {
+		return 0, fmt.Errorf("write partial sidecar - %s", err)
+	}
+
+	return 0, nil
+}
+
+// resumableStream downloads the stream in chunks using Range requests, appending to what is already on disk.
+// The sidecar is kept when the stream is complete, so a failed sibling stream or merge does not throw it away
+func (d Download) resumableStream(ctx context.Context, client youtube.Client, video *youtube.Video, format *youtube.Format, filename string) error {
+	offset, err := resumeOffset(filename, format)
+	if err != nil {
+		return err
+	}
+	if offset == format.ContentLength {
+		return nil
+	}
+
+	url, err := client.GetStreamURLContext(ctx, video, format)
+	if err != nil {
+		return fmt.Errorf("get the stream URL - %w", err)
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open destination file - %s", err)
+	}
+	defer file.Close()
+
+	streamClient := *client.HTTPClient
+	streamClient.Timeout = 0
+
+	for offset < format.ContentLength {
+		end := min(offset+streamChunkSize, format.ContentLength) - 1
+
+		written, err := rangeRequest(ctx, &streamClient, url, offset, end, file)
+		if err != nil {
+			return fmt.Errorf("range %d-%d - Problem streaming the video - %w", offset+written, end, err)
+		}
+		offset += written
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat destination file - %s", err)
+	}
+	if info.Size() != format.ContentLength {
+		return fmt.Errorf("downloaded size %d does not match content length %d", info.Size(), format.ContentLength)
+	}
+
+	return nil
+}
+
+// rangeRequest gets the bytes from start to end (inclusive) and writes them to w. The request is stopped when
+// nothing is received for streamIdleTimeout
+func rangeRequest(ctx context.Context, httpClient *http.Client, url string, start, end int64, w io.Writer) (int64, error) {
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, idleError(ctx, reqCtx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return 0, fmt.Errorf("unexpected status code %d: %w", resp.StatusCode, models.ErrRateLimited)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	written, err := io.CopyN(w, idleReader{reader: resp.Body, idle: idle}, end-start+1)
+	return written, idleError(ctx, reqCtx, err)
+}
+
+// idleReader pushes the idle timeout back every time something is read
+type idleReader struct {
+	reader io.Reader
+	idle   *time.Timer
+}
+
+func (r idleReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.idle.Reset(streamIdleTimeout)
+	}
+	return n, err
+}
+
+// idleError tells a request stopped by the idle timeout apart from one that was cancelled
+func idleError(ctx, reqCtx context.Context, err error) error {
+	if err != nil && ctx.Err() == nil && reqCtx.Err() != nil {
+		return fmt.Errorf("nothing received for %s: %w", streamIdleTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+package stateStore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"download-youtube/models"
+)
+
+// jsonMeta is what is saved about the sync, next to the JSON file with the videos
+type jsonMeta struct {
+	LastSync time.Time `json:"lastSync"`
+}
+
+// JSONStore keeps every video in one JSON file, the whole file is written on every change
+type JSONStore struct {
+	path   string
+	mu     sync.Mutex
+	videos []models.Video
+	loaded bool
+}
+
+// NewJSONStore uses the JSON file at path, it is created on the first save
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+func (s *JSONStore) Load() ([]models.Video, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return append([]models.Video(nil), s.videos...), nil
+}
+
+func (s *JSONStore) Save(videos []models.Video) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.videos = append([]models.Video(nil), videos...)
+	s.loaded = true
+	return s.write()
+}
+
+func (s *JSONStore) Upsert(video models.Video) error {
+	return s.update(video, func(saved *models.Video) {
+		*saved = video
+	})
+}
+
+func (s *JSONStore) MarkDownloaded(video models.Video) error {
+	return s.update(video, func(saved *models.Video) {
+		saved.Downloaded = true
+		saved.ImageSaved = video.ImageSaved
+		saved.Media = video.Media
+		saved.Error = ""
+	})
+}
+
+func (s *JSONStore) RecordError(video models.Video) error {
+	return s.update(video, func(saved *models.Video) {
+		saved.Downloaded = false
+		saved.ImageSaved = video.ImageSaved
+		saved.Error = video.Error
+	})
+}
+
+func (s *JSONStore) Pending() ([]models.Video, error) {
+	videos, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []models.Video
+	for _, video := range videos {
+		if (!video.Downloaded || !video.ImageSaved) && video.Error == "" {
+			pending = append(pending, video)
+		}
+	}
+	return pending, nil
+}
+
+func (s *JSONStore) LastSync() (time.Time, error) {
+	var meta jsonMeta
+	metaByte, err := models.ReadState(s.metaPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := json.Unmarshal(metaByte, &meta); err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", models.ErrStateCorrupt, s.metaPath(), err)
+	}
+	return meta.LastSync, nil
+}
+
+func (s *JSONStore) SetLastSync(syncTime time.Time) error {
+	metaByte, err := json.Marshal(jsonMeta{LastSync: syncTime})
+	if err != nil {
+		return err
+	}
+	return models.WriteState(s.metaPath(), metaByte, 0)
+}
+
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// update changes the saved video with the same key, a video that is not saved yet is added
+func (s *JSONStore) update(video models.Video, change func(saved *models.Video)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	key := videoKey(video)
+	found := false
+	for i := range s.videos {
+		if videoKey(s.videos[i]) == key {
+			change(&s.videos[i])
+			found = true
+			break
+		}
+	}
+	if !found {
+		s.videos = append(s.videos, video)
+	}
+
+	return s.write()
+}
+
+// load reads the file the first time it is needed, a missing file is an empty store
+func (s *JSONStore) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	jsonByte, err := models.ReadState(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(jsonByte, &s.videos); err != nil {
+		return fmt.Errorf("%w: %s: %v", models.ErrStateCorrupt, s.path, err)
+	}
+	s.loaded = true
+	return nil
+}
+
+// metaPath is the file with the sync info, e.g. Show-channel-data.sync.json
+func (s *JSONStore) metaPath() string {
+	return strings.TrimSuffix(s.path, ".json") + ".sync.json"
+}
+
+func (s *JSONStore) write() error {
+	videosJSON, err := json.Marshal(s.videos)
+	if err != nil {
+		return err
+	}
+	return models.WriteState(s.path, videosJSON, models.StateBackups)
+}
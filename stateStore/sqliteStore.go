@@ -0,0 +1,207 @@
+package stateStore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"download-youtube/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema keeps the whole video as JSON in data, the columns used to select videos are kept next to it
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS videos (
+	key          TEXT PRIMARY KEY,
+	id           TEXT NOT NULL,
+	title        TEXT NOT NULL,
+	season       TEXT NOT NULL,
+	episode      TEXT NOT NULL,
+	published_at TEXT NOT NULL,
+	downloaded   INTEGER NOT NULL,
+	image_saved  INTEGER NOT NULL,
+	error        TEXT NOT NULL,
+	data         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS videos_season_episode ON videos (season, episode);
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+const sqliteUpsert = `
+INSERT INTO videos (key, id, title, season, episode, published_at, downloaded, image_saved, error, data)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (key) DO UPDATE SET
+	id = excluded.id, title = excluded.title, season = excluded.season, episode = excluded.episode,
+	published_at = excluded.published_at, downloaded = excluded.downloaded, image_saved = excluded.image_saved,
+	error = excluded.error, data = excluded.data
+`
+
+// SQLiteStore keeps every video as a row, so a download only writes the row of that video
+type SQLiteStore struct {
+	db   *sql.DB
+	path string
+}
+
+// OpenSQLiteStore opens or creates the database at path
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(FULL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite has one writer at a time, the workers take turns
+	db.SetMaxOpenConns(1)
+
+	var check string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&check); err != nil || check != "ok" {
+		db.Close()
+		if err == nil {
+			err = fmt.Errorf("%s", check)
+		}
+		return nil, fmt.Errorf("%w: %s: %v", models.ErrStateCorrupt, path, err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating tables in %s: %v", path, err)
+	}
+
+	return &SQLiteStore{db: db, path: path}, nil
+}
+
+func (s *SQLiteStore) Load() ([]models.Video, error) {
+	return s.query("SELECT data FROM videos ORDER BY rowid")
+}
+
+func (s *SQLiteStore) Save(videos []models.Video) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM videos"); err != nil {
+		return err
+	}
+	for _, video := range videos {
+		if err := upsert(tx, video); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Upsert(video models.Video) error {
+	return upsert(s.db, video)
+}
+
+func (s *SQLiteStore) MarkDownloaded(video models.Video) error {
+	media, err := json.Marshal(video.Media)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`
+UPDATE videos SET downloaded = 1, image_saved = ?, error = '',
+	data = json_set(data, '$.downloaded', json('true'), '$.imageSaved', json(?), '$.error', '', '$.media', json(?))
+WHERE key = ?`, video.ImageSaved, jsonBool(video.ImageSaved), string(media), videoKey(video))
+	return s.upsertMissing(result, err, video)
+}
+
+func (s *SQLiteStore) RecordError(video models.Video) error {
+	result, err := s.db.Exec(`
+UPDATE videos SET downloaded = 0, image_saved = ?, error = ?,
+	data = json_set(data, '$.downloaded', json('false'), '$.imageSaved', json(?), '$.error', ?)
+WHERE key = ?`, video.ImageSaved, video.Error, jsonBool(video.ImageSaved), video.Error, videoKey(video))
+	return s.upsertMissing(result, err, video)
+}
+
+func (s *SQLiteStore) Pending() ([]models.Video, error) {
+	return s.query("SELECT data FROM videos WHERE error = '' AND NOT (downloaded AND image_saved) ORDER BY rowid")
+}
+
+func (s *SQLiteStore) LastSync() (time.Time, error) {
+	var value string
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = 'last_sync'").Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (s *SQLiteStore) SetLastSync(syncTime time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO meta (key, value) VALUES ('last_sync', ?)
+ON CONFLICT (key) DO UPDATE SET value = excluded.value`, syncTime.UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// upsertMissing adds the whole video when the update did not find it
+func (s *SQLiteStore) upsertMissing(result sql.Result, err error, video models.Video) error {
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return s.Upsert(video)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) query(query string) ([]models.Video, error) {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var videos []models.Video
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var video models.Video
+		if err := json.Unmarshal([]byte(data), &video); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", models.ErrStateCorrupt, s.path, err)
+		}
+		videos = append(videos, video)
+	}
+
+	return videos, rows.Err()
+}
+
+// execer is a database or a transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func upsert(db execer, video models.Video) error {
+	data, err := json.Marshal(video)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(sqliteUpsert, videoKey(video), video.ID, video.Title, video.Season, video.Episode,
+		video.PublishedAt, video.Downloaded, video.ImageSaved, video.Error, string(data))
+	return err
+}
+
+func jsonBool(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,63 @@
+package stateStore
+
+import (
+	"fmt"
+	"time"
+
+	"download-youtube/models"
+)
+
+// Store saves what is known about the videos of a show
+type Store interface {
+	// Load returns every saved video in the order they were added
+	Load() ([]models.Video, error)
+	// Save replaces every saved video, used after a sync or when the whole state changed
+	Save(videos []models.Video) error
+	// Upsert saves the video, replacing the saved one with the same ID
+	Upsert(video models.Video) error
+	// MarkDownloaded saves that the video and thumbnail are downloaded, with the probed media, and clears the error
+	MarkDownloaded(video models.Video) error
+	// RecordError saves why the download of the video failed
+	RecordError(video models.Video) error
+	// Pending returns the videos missing media that have not failed before
+	Pending() ([]models.Video, error)
+	// LastSync is when the last successful sync started, zero when there was none
+	LastSync() (time.Time, error)
+	// SetLastSync saves when the successful sync started
+	SetLastSync(syncTime time.Time) error
+	Close() error
+}
+
+// Open opens the store of the show, STATE_STORE picks the JSON file or the SQLite database
+func Open(envVar models.EnvVar) (Store, error) {
+	switch envVar.StateStore {
+	case "", models.StoreJSON:
+		return NewJSONStore(JSONPath(envVar)), nil
+	case models.StoreSQLite:
+		return OpenSQLiteStore(SQLitePath(envVar))
+	default:
+		return nil, fmt.Errorf("%w: unknown STATE_STORE %q", models.ErrInvalidConfig, envVar.StateStore)
+	}
+}
+
+// JSONPath is where the JSON store of the show is saved
+func JSONPath(envVar models.EnvVar) string {
+	return fmt.Sprintf("%s%s-channel-data.json", envVar.SaveLoc, envVar.ChannelName)
+}
+
+// SQLitePath is where the SQLite store of the show is saved
+func SQLitePath(envVar models.EnvVar) string {
+	return fmt.Sprintf("%s%s-channel-data.db", envVar.SaveLoc, envVar.ChannelName)
+}
+
+// videoKey identifies a video in the store, videos saved before the ID was stored use their URL or title
+func videoKey(video models.Video) string {
+	switch {
+	case video.ID != "":
+		return video.ID
+	case video.URL != "":
+		return video.URL
+	default:
+		return video.Title
+	}
+}
@@ -0,0 +1,218 @@
+package stateStore
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"download-youtube/models"
+)
+
+// stores opens every Store in dir, opening the same dir again has what was saved
+var stores = []struct {
+	name string
+	open func(t *testing.T, dir string) Store
+}{
+	{"json", func(t *testing.T, dir string) Store {
+		return NewJSONStore(filepath.Join(dir, "Show-channel-data.json"))
+	}},
+	{"sqlite", func(t *testing.T, dir string) Store {
+		store, err := OpenSQLiteStore(filepath.Join(dir, "Show-channel-data.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+// storeContract is what every store does: the videos are saved, changed and then loaded from the reopened store
+var storeContract = []struct {
+	name   string
+	saved  []models.Video
+	change func(t *testing.T, store Store)
+	want   []models.Video
+	// pending are the keys Pending returns
+	pending []string
+}{
+	{
+		name:    "save and load keep the order",
+		saved:   []models.Video{{ID: "c", Title: "C"}, {ID: "a", Title: "A"}, {ID: "b", Title: "B"}},
+		want:    []models.Video{{ID: "c", Title: "C"}, {ID: "a", Title: "A"}, {ID: "b", Title: "B"}},
+		pending: []string{"c", "a", "b"},
+	},
+	{
+		name:  "save drops the videos that are not passed",
+		saved: []models.Video{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}, {ID: "c", Title: "C"}},
+		change: func(t *testing.T, store Store) {
+			check(t, store.Save([]models.Video{{ID: "a", Title: "A"}, {ID: "c", Title: "C merged"}}))
+		},
+		want:    []models.Video{{ID: "a", Title: "A"}, {ID: "c", Title: "C merged"}},
+		pending: []string{"a", "c"},
+	},
+	{
+		name: "pending skips downloaded and errored videos",
+		saved: []models.Video{
+			{ID: "new"},
+			{ID: "done", Downloaded: true, ImageSaved: true},
+			{ID: "no-thumb", Downloaded: true},
+			{ID: "failed", Error: "403"},
+			{ID: "failed-done", Downloaded: true, ImageSaved: true, Error: "stale"},
+		},
+		want: []models.Video{
+			{ID: "new"},
+			{ID: "done", Downloaded: true, ImageSaved: true},
+			{ID: "no-thumb", Downloaded: true},
+			{ID: "failed", Error: "403"},
+			{ID: "failed-done", Downloaded: true, ImageSaved: true, Error: "stale"},
+		},
+		pending: []string{"new", "no-thumb"},
+	},
+	{
+		name:  "mark downloaded keeps the other fields",
+		saved: []models.Video{{ID: "a", Title: "A", Season: "01", Episode: "02", Error: "timeout"}},
+		change: func(t *testing.T, store Store) {
+			media := &models.MediaInfo{Width: 1920, Height: 1080}
+			check(t, store.MarkDownloaded(models.Video{ID: "a", Title: "ignored", ImageSaved: true, Media: media}))
+		},
+		want: []models.Video{{ID: "a", Title: "A", Season: "01", Episode: "02", Downloaded: true, ImageSaved: true,
+			Media: &models.MediaInfo{Width: 1920, Height: 1080}}},
+	},
+	{
+		name:  "mark downloaded adds a video that is not saved",
+		saved: []models.Video{{ID: "a", Title: "A"}},
+		change: func(t *testing.T, store Store) {
+			check(t, store.MarkDownloaded(models.Video{ID: "b", Title: "B", Downloaded: true, ImageSaved: true}))
+		},
+		want:    []models.Video{{ID: "a", Title: "A"}, {ID: "b", Title: "B", Downloaded: true, ImageSaved: true}},
+		pending: []string{"a"},
+	},
+	{
+		name:  "record error adds a video that is not saved",
+		saved: []models.Video{},
+		change: func(t *testing.T, store Store) {
+			check(t, store.RecordError(models.Video{ID: "a", Title: "A", Error: "403"}))
+		},
+		want: []models.Video{{ID: "a", Title: "A", Error: "403"}},
+	},
+	{
+		name:  "record error keeps the other fields",
+		saved: []models.Video{{ID: "a", Title: "A", Downloaded: true, ImageSaved: true}},
+		change: func(t *testing.T, store Store) {
+			check(t, store.RecordError(models.Video{ID: "a", Error: "merge failed"}))
+		},
+		want: []models.Video{{ID: "a", Title: "A", Error: "merge failed"}},
+	},
+	{
+		name: "videos without an ID are found by URL and then title",
+		saved: []models.Video{
+			{URL: "https://www.youtube.com/watch?v=old", Title: "Old"},
+			{Title: "No URL"},
+		},
+		change: func(t *testing.T, store Store) {
+			check(t, store.MarkDownloaded(models.Video{URL: "https://www.youtube.com/watch?v=old", ImageSaved: true}))
+			check(t, store.RecordError(models.Video{Title: "No URL", Error: "gone"}))
+		},
+		want: []models.Video{
+			{URL: "https://www.youtube.com/watch?v=old", Title: "Old", Downloaded: true, ImageSaved: true},
+			{Title: "No URL", Error: "gone"},
+		},
+	},
+	{
+		name:  "upsert replaces the video with the same ID",
+		saved: []models.Video{{ID: "a", Title: "A"}, {ID: "b", Title: "B"}},
+		change: func(t *testing.T, store Store) {
+			check(t, store.Upsert(models.Video{ID: "a", Title: "A renamed", Downloaded: true}))
+		},
+		want:    []models.Video{{ID: "a", Title: "A renamed", Downloaded: true}, {ID: "b", Title: "B"}},
+		pending: []string{"a", "b"},
+	},
+}
+
+func TestStoreContract(t *testing.T) {
+	for _, store := range stores {
+		for _, test := range storeContract {
+			t.Run(store.name+"/"+test.name, func(t *testing.T) {
+				dir := t.TempDir()
+				s := store.open(t, dir)
+				check(t, s.Save(test.saved))
+				if test.change != nil {
+					test.change(t, s)
+				}
+				check(t, s.Close())
+
+				// Everything has to be saved, not only kept in memory
+				s = store.open(t, dir)
+				defer s.Close()
+
+				got, err := s.Load()
+				check(t, err)
+				if !equalVideos(got, test.want) {
+					t.Errorf("loaded %+v\nwant %+v", got, test.want)
+				}
+
+				pending, err := s.Pending()
+				check(t, err)
+				var pendingKeys []string
+				for _, video := range pending {
+					pendingKeys = append(pendingKeys, videoKey(video))
+				}
+				if !slices.Equal(pendingKeys, test.pending) {
+					t.Errorf("pending %q, want %q", pendingKeys, test.pending)
+				}
+			})
+		}
+	}
+}
+
+func TestStoreLastSync(t *testing.T) {
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := store.open(t, dir)
+
+			lastSync, err := s.LastSync()
+			check(t, err)
+			if !lastSync.IsZero() {
+				t.Errorf("last sync of a new store = %v, want zero", lastSync)
+			}
+
+			synced := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+			check(t, s.SetLastSync(synced))
+			check(t, s.Close())
+
+			s = store.open(t, dir)
+			defer s.Close()
+			lastSync, err = s.LastSync()
+			check(t, err)
+			if !lastSync.Equal(synced) {
+				t.Errorf("last sync = %v, want %v", lastSync, synced)
+			}
+		})
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// equalVideos compares the fields the stores keep, an empty list is the same as none
+func equalVideos(got, want []models.Video) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		a, b := got[i], want[i]
+		if a.ID != b.ID || a.URL != b.URL || a.Title != b.Title || a.Season != b.Season || a.Episode != b.Episode ||
+			a.Downloaded != b.Downloaded || a.ImageSaved != b.ImageSaved || a.Error != b.Error {
+			return false
+		}
+		if (a.Media == nil) != (b.Media == nil) || (a.Media != nil && *a.Media != *b.Media) {
+			return false
+		}
+	}
+	return true
+}
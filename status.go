@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"download-youtube/models"
+)
+
+// Status prints how many videos are downloaded, pending and errored
+func (d Download) Status() error {
+	videos, err := d.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	var downloaded, pending, errored []models.Video
+	for _, video := range videos {
+		switch {
+		case video.Error != "":
+			errored = append(errored, video)
+		case video.Downloaded && video.ImageSaved:
+			downloaded = append(downloaded, video)
+		default:
+			pending = append(pending, video)
+		}
+	}
+
+	fmt.Printf("%s\n", d.ShowName)
+	fmt.Printf("  Total:      %d\n", len(videos))
+	fmt.Printf("  Downloaded: %d\n", len(downloaded))
+	fmt.Printf("  Pending:    %d\n", len(pending))
+	fmt.Printf("  Errored:    %d\n", len(errored))
+
+	for _, video := range errored {
+		fmt.Printf("    S%sE%s - %s: %s\n", video.Season, video.Episode, video.Title, video.Error)
+	}
+
+	return nil
+}
+
+// Verify checks that the files on disk match the state, with fix the missing ones are marked to be downloaded again
+// and the ones that exist are marked as downloaded
+func (d Download) Verify(fix bool) error {
+	videos, err := d.Store.Load()
+	if err != nil {
+		return err
+	}
+
+	mismatches := 0
+	for i, video := range videos {
+		videoFile := video.Filepath + ".mp4"
+		thumbFile := fmt.Sprintf("%s-thumb.jpg", video.Filepath)
+
+		if video.Downloaded && !fileExists(videoFile) {
+			log.Printf("Marked as downloaded but missing: %s", videoFile)
+			videos[i].Downloaded = false
+			mismatches++
+		}
+		if !video.Downloaded && fileExists(videoFile) && !fileExists(sidecarPath(videoFile)) {
+			log.Printf("Not marked as downloaded but exists: %s", videoFile)
+			videos[i].Downloaded = true
+			videos[i].Error = ""
+			if media, err := probeMedia(videoFile); err != nil {
+				log.Print(err)
+			} else {
+				videos[i].Media = media
+			}
+			mismatches++
+		}
+		if video.ImageSaved && !fileExists(thumbFile) {
+			log.Printf("Marked as saved but missing: %s", thumbFile)
+			videos[i].ImageSaved = false
+			mismatches++
+		}
+	}
+
+	if mismatches == 0 {
+		log.Printf("All %d videos match the files on disk", len(videos))
+		return nil
+	}
+
+	if !fix {
+		return fmt.Errorf("%d files do not match the state, run verify -fix to update the state", mismatches)
+	}
+
+	if err := d.Store.Save(videos); err != nil {
+		return fmt.Errorf("problem with saving the videos: %w", err)
+	}
+	log.Printf("Marked the missing files to be downloaded again and the existing ones as downloaded")
+
+	return nil
+}
+
+// fileExists checks that the file exists and is not empty
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.Size() > 0
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"download-youtube/models"
+)
+
+// defaultTemplates are the built-in Kodi NFO templates, used when the template dir does not have its own
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+const (
+	episodeTemplate = "episode.nfo.tmpl"
+	seasonTemplate  = "season.nfo.tmpl"
+	tvShowTemplate  = "tvshow.nfo.tmpl"
+)
+
+type nfoTemplates struct {
+	Episode *template.Template
+	Season  *template.Template
+	TvShow  *template.Template
+}
+
+// episodeTemplateData is what episode.nfo.tmpl gets. The NFO fields can be used directly, e.g. {{.Title}},
+// everything saved about the video is in {{.Video}} and the show in {{.Show}}
+type episodeTemplateData struct {
+	models.NFOEpisodeDetails
+	Video models.Video
+	Show  models.Show
+}
+
+// seasonTemplateData is what season.nfo.tmpl gets, with the episodes of the season ordered by episode number
+type seasonTemplateData struct {
+	models.NFOSeasonDetails
+	Episodes []models.Video
+	Show     models.Show
+}
+
+// tvShowTemplateData is what tvshow.nfo.tmpl gets
+type tvShowTemplateData struct {
+	models.NFOTvShowDetails
+	Show models.Show
+}
+
+// templateFuncs are available in every template. Values are not escaped on their own, so text and attributes
+// should go through xml, e.g. {{.Title | xml}}
+var templateFuncs = template.FuncMap{
+	"xml": escapeXML,
+}
+
+// escapeXML escapes a value for XML text or attribute content. Characters XML does not allow are replaced
+func escapeXML(value any) string {
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(fmt.Sprint(value)))
+	return escaped.String()
+}
+
+// loadTemplates reads the NFO templates, a template in templateDir replaces the built-in one with the same name
+func loadTemplates(templateDir string) (nfoTemplates, error) {
+	var templates nfoTemplates
+	var err error
+
+	if templates.Episode, err = loadTemplate(templateDir, episodeTemplate); err != nil {
+		return templates, err
+	}
+	if templates.Season, err = loadTemplate(templateDir, seasonTemplate); err != nil {
+		return templates, err
+	}
+	if templates.TvShow, err = loadTemplate(templateDir, tvShowTemplate); err != nil {
+		return templates, err
+	}
+
+	return templates, nil
+}
+
+func loadTemplate(templateDir, name string) (*template.Template, error) {
+	if templateDir != "" {
+		path := filepath.Join(templateDir, name)
+		content, err := os.ReadFile(path)
+		if err == nil {
+			log.Printf("Using template: %s", path)
+			tmpl, err := template.New(name).Funcs(templateFuncs).Parse(string(content))
+			if err != nil {
+				return nil, fmt.Errorf("%w: error parsing template %s: %v", models.ErrInvalidConfig, path, err)
+			}
+			return tmpl, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: error reading template %s: %v", models.ErrInvalidConfig, path, err)
+		}
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).ParseFS(defaultTemplates, "templates/"+name)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing built-in template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// writeIfChanged executes the template and only writes the file when the content differs from what is on disk.
+// Returns if the file was written
+func writeIfChanged(tmpl *template.Template, data any, filename string) (bool, error) {
+	var content bytes.Buffer
+	if err := tmpl.Execute(&content, data); err != nil {
+		return false, fmt.Errorf("error executing template: %v", err)
+	}
+	if err := checkXML(content.Bytes()); err != nil {
+		return false, fmt.Errorf("template %s did not produce valid XML for %s: %v", tmpl.Name(), filename, err)
+	}
+
+	existing, err := os.ReadFile(filename)
+	if err == nil && bytes.Equal(existing, content.Bytes()) {
+		return false, nil
+	}
+
+	if err := os.WriteFile(filename, content.Bytes(), 0644); err != nil {
+		return false, fmt.Errorf("error writing file: %v", err)
+	}
+
+	return true, nil
+}
+
+// checkXML parses the rendered NFO so a broken template or a missing xml escape never reaches Kodi
+func checkXML(content []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"download-youtube/models"
+)
+
+// trickyText has everything that breaks XML when it is not escaped
+const trickyText = "Tom & Jerry <live> \"quoted\" 'single' ]]> end\ttab\nnew line & more"
+
+// trickyControl has a control character XML does not allow, it comes back as U+FFFD
+const trickyControl = "Bell\x07 & <whistle>"
+
+// roundTrip is what the characters XML does not allow come back as
+func roundTrip(value string) string {
+	return strings.ReplaceAll(value, "\x07", "\uFFFD")
+}
+
+func readNfo(t *testing.T, filename string, out any) {
+	t.Helper()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(content, out); err != nil {
+		t.Fatalf("%s is not valid XML: %v\n%s", filename, err, content)
+	}
+}
+
+func builtInTemplates(t *testing.T) nfoTemplates {
+	t.Helper()
+
+	templates, err := loadTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return templates
+}
+
+func TestEpisodeNfoRoundTrip(t *testing.T) {
+	templates := builtInTemplates(t)
+	dir := t.TempDir()
+
+	video := models.Video{
+		ID:          "abc123",
+		Title:       trickyText,
+		Description: trickyControl + "\n" + trickyText,
+		Tags:        []string{"a & b", "<tag>", "\"q\"", "]]>", trickyControl},
+		Category:    "Film & Animation",
+		Season:      "01",
+		Episode:     "02",
+		URL:         "https://www.youtube.com/watch?v=abc123&t=1",
+		Filename:    "S01E02 - Tom & Jerry <live>",
+		Filepath:    filepath.Join(dir, "S01E02"),
+	}
+	show := models.Show{Name: "Show & <Co>"}
+
+	if _, err := generateEpisodeNfo(video, show, templates.Episode); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Title            string   `xml:"title"`
+		ShowTitle        string   `xml:"showtitle"`
+		Plot             string   `xml:"plot"`
+		Genres           []string `xml:"genre"`
+		Tags             []string `xml:"tag"`
+		Source           string   `xml:"source"`
+		OriginalFilename string   `xml:"original_filename"`
+	}
+	readNfo(t, video.Filepath+".nfo", &got)
+
+	wantTags := make([]string, len(video.Tags))
+	for i, tag := range video.Tags {
+		wantTags[i] = roundTrip(tag)
+	}
+
+	if got.Title != video.Title {
+		t.Errorf("title = %q, want %q", got.Title, video.Title)
+	}
+	if got.ShowTitle != show.Name {
+		t.Errorf("showtitle = %q, want %q", got.ShowTitle, show.Name)
+	}
+	if want := roundTrip(video.Description); got.Plot != want {
+		t.Errorf("plot = %q, want %q", got.Plot, want)
+	}
+	if !slices.Equal(got.Genres, []string{video.Category}) {
+		t.Errorf("genres = %q, want %q", got.Genres, video.Category)
+	}
+	if !slices.Equal(got.Tags, wantTags) {
+		t.Errorf("tags = %q, want %q", got.Tags, wantTags)
+	}
+	if got.Source != video.URL {
+		t.Errorf("source = %q, want %q", got.Source, video.URL)
+	}
+	if got.OriginalFilename != video.Filename {
+		t.Errorf("original_filename = %q, want %q", got.OriginalFilename, video.Filename)
+	}
+}
+
+func TestSeasonNfoRoundTrip(t *testing.T) {
+	templates := builtInTemplates(t)
+	filename := filepath.Join(t.TempDir(), "season.nfo")
+
+	data := seasonTemplateData{
+		NFOSeasonDetails: models.NFOSeasonDetails{Title: trickyText, Year: "2024", SeasonNumber: "1", Plot: trickyControl},
+		Show:             models.Show{Name: trickyText},
+	}
+	if _, err := writeIfChanged(templates.Season, data, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Title string `xml:"title"`
+		Plot  string `xml:"plot"`
+	}
+	readNfo(t, filename, &got)
+
+	if got.Title != trickyText {
+		t.Errorf("title = %q, want %q", got.Title, trickyText)
+	}
+	if want := roundTrip(trickyControl); got.Plot != want {
+		t.Errorf("plot = %q, want %q", got.Plot, want)
+	}
+}
+
+func TestTvShowNfoRoundTrip(t *testing.T) {
+	templates := builtInTemplates(t)
+	dir := t.TempDir()
+
+	show := models.Show{
+		Name: "Show & <Co>",
+		Channel: models.Channel{
+			Title:       trickyText,
+			Description: trickyControl + "\n" + trickyText,
+			Country:     "\"NO\"",
+			AvatarURL:   "https://yt3.example.com/a.jpg?s=1&b=2",
+		},
+	}
+	generateTvShowNfo(show, "2016", dir, templates.TvShow)
+
+	var got struct {
+		Title         string `xml:"title"`
+		OriginalTitle string `xml:"originaltitle"`
+		Plot          string `xml:"plot"`
+		Country       string `xml:"country"`
+		Actor         struct {
+			Name  string `xml:"name"`
+			Thumb string `xml:"thumb"`
+		} `xml:"actor"`
+	}
+	readNfo(t, filepath.Join(dir, "tvshow.nfo"), &got)
+
+	if got.Title != show.Name {
+		t.Errorf("title = %q, want %q", got.Title, show.Name)
+	}
+	if got.OriginalTitle != show.Channel.Title {
+		t.Errorf("originaltitle = %q, want %q", got.OriginalTitle, show.Channel.Title)
+	}
+	if want := roundTrip(show.Channel.Description); got.Plot != want {
+		t.Errorf("plot = %q, want %q", got.Plot, want)
+	}
+	if got.Country != show.Channel.Country {
+		t.Errorf("country = %q, want %q", got.Country, show.Channel.Country)
+	}
+	if got.Actor.Name != show.Channel.Title || got.Actor.Thumb != show.Channel.AvatarURL {
+		t.Errorf("actor = %q, want %q and %q", got.Actor, show.Channel.Title, show.Channel.AvatarURL)
+	}
+}
+
+func TestWriteIfChangedRefusesInvalidXML(t *testing.T) {
+	tmpl, err := loadTemplate("", seasonTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err = tmpl.New("broken").Parse("<season><title>{{.Title}}</title></season>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(t.TempDir(), "season.nfo")
+
+	data := seasonTemplateData{NFOSeasonDetails: models.NFOSeasonDetails{Title: trickyText}}
+	if _, err := writeIfChanged(tmpl, data, filename); err == nil {
+		t.Fatal("unescaped template was written")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("%s exists after invalid XML: %v", filename, err)
+	}
+}